@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,8 +16,14 @@ import (
 	"github.com/saixiaoxi/high-availability-system/internal/middleware"
 	"github.com/saixiaoxi/high-availability-system/internal/monitors"
 	"github.com/saixiaoxi/high-availability-system/internal/service"
+	"github.com/saixiaoxi/high-availability-system/pkg/bulkhead"
+	"github.com/saixiaoxi/high-availability-system/pkg/circuitbreaker"
 	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
 	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
@@ -26,37 +33,32 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// 设置Gin模式
-	gin.SetMode(viper.GetString("server.mode"))
-
-	// 创建重试配置
-	retryConfig := &retry.Config{
-		MaxAttempts:         viper.GetInt("retry.max_attempts"),
-		InitialInterval:     viper.GetDuration("retry.initial_interval"),
-		MaxInterval:         viper.GetDuration("retry.max_interval"),
-		Multiplier:          viper.GetFloat64("retry.multiplier"),
-		RandomizationFactor: viper.GetFloat64("retry.randomization_factor"),
+	// 创建日志器，未注入日志器的组件使用全局日志器
+	appLogger, err := loadLogger()
+	if err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
 	}
+	logger.SetDefault(appLogger)
+	defer logger.Close(appLogger)
 
-	// 创建外部服务客户端
-	externalService := service.NewMockExternalService(
-		"http://payment-service:8080",
-		"http://notification-service:8080",
-		5*time.Second,
-		0.3, // 30%的模拟失败率
-	)
+	// 标准库日志也写入日志器
+	log.SetFlags(0)
+	log.SetOutput(appLogger.WriterLevel(logrus.WarnLevel))
 
-	// 创建业务服务
-	svc := service.NewService(retryConfig, externalService)
+	if viper.ConfigFileUsed() == "" {
+		appLogger.Warn("Config file not found, using defaults")
+	}
 
-	// 创建API处理器
-	handler := api.NewHandler(svc)
+	// 设置Gin模式
+	gin.SetMode(viper.GetString("server.mode"))
 
 	// 创建Prometheus监控
-	prometheusMonitor := monitors.NewPrometheusMonitor(viper.GetString("monitoring.prometheus.endpoint"))
-	err := prometheusMonitor.StartServer(":9090")
-	if err != nil {
-		log.Printf("Warning: Failed to start Prometheus metrics server: %v", err)
+	prometheusMonitor := monitors.NewPrometheusMonitor(
+		viper.GetString("monitoring.prometheus.endpoint"),
+		monitors.WithPrometheusLogger(appLogger),
+	)
+	if err := prometheusMonitor.StartServer(":9090"); err != nil {
+		appLogger.Warnf("Failed to start Prometheus metrics server: %v", err)
 	}
 
 	// 创建监控容错策略
@@ -71,55 +73,240 @@ func main() {
 		prometheusMonitor,
 		loggingFallback,
 		viper.GetDuration("monitoring.fallback.periodic_check"),
+		monitors.WithMonitorLogger(appLogger),
+		monitors.WithMonitorDamping(loadDampingConfig("monitoring.fallback.damping")),
 	)
 
 	// 创建定期刷新器
 	flusher := monitors.NewPeriodicFlusher(loggingFallback, 30*time.Second)
 	flusher.Start()
 
-	// 创建健康检查
-	healthChecker := healthcheck.NewChecker()
+	// 创建追踪，采集器不可用时跨度写入本地文件或被丢弃，不影响请求处理
+	tracerProvider, err := loadTracerProvider(appLogger)
+	if err != nil {
+		appLogger.Fatalf("Invalid tracing configuration: %v", err)
+	}
+	if tracerProvider != nil {
+		tracing.SetProvider(tracerProvider)
+	}
+
+	// 创建重试预算，业务重试和中间件重试共享同一份预算
+	retryBudget := loadBudget("retry.budget")
+
+	// 导出重试预算指标
+	var budgetReporter *monitors.RetryBudgetReporter
+	if retryBudget != nil {
+		budgetReporter = monitors.NewRetryBudgetReporter(monitor, "default", retryBudget, 15*time.Second)
+		budgetReporter.Start()
+	}
+
+	// 创建重试配置
+	retryConfig, err := loadRetryConfig("retry", nil, retryBudget)
+	if err != nil {
+		appLogger.Fatalf("Invalid retry configuration: %v", err)
+	}
+	paymentRetryConfig, err := loadRetryConfig("retry.payment", retryConfig, retryBudget)
+	if err != nil {
+		appLogger.Fatalf("Invalid payment retry configuration: %v", err)
+	}
+	notificationRetryConfig, err := loadRetryConfig("retry.notification", retryConfig, retryBudget)
+	if err != nil {
+		appLogger.Fatalf("Invalid notification retry configuration: %v", err)
+	}
 
-	// 添加HTTP健康检查
-	healthChecker.AddCheck(healthcheck.NewHTTPCheck(
-		"payment-service",
-		"http://payment-service:8080/health",
+	// 为外部服务调用的重试附加指标
+	retryMetrics := monitors.NewRetryMetrics(monitor)
+	paymentRetryConfig = retryMetrics.Instrument(paymentRetryConfig, "payment")
+	notificationRetryConfig = retryMetrics.Instrument(notificationRetryConfig, "notification")
+
+	// 创建外部服务客户端
+	mockExternalService := service.NewMockExternalService(
+		"http://payment-service:8080",
+		"http://notification-service:8080",
 		5*time.Second,
-	))
+		0.3, // 30%的模拟失败率
+	)
+
+	// 创建熔断器
+	breakerMetrics := monitors.NewCircuitBreakerMetrics(monitor)
+	paymentBreaker := loadCircuitBreaker("external_services.payment_service.circuit_breaker", "payment-service", breakerMetrics)
+	notificationBreaker := loadCircuitBreaker("external_services.notification_service.circuit_breaker", "notification-service", breakerMetrics)
+	breakerService := service.NewCircuitBreakerExternalService(mockExternalService, paymentBreaker, notificationBreaker)
+
+	// 创建隔离舱，隔离舱在熔断器之外，被拒绝的请求不计入熔断统计
+	paymentBulkhead := loadBulkhead("external_services.payment_service.bulkhead", "payment-service")
+	notificationBulkhead := loadBulkhead("external_services.notification_service.bulkhead", "notification-service")
+	bulkheadService := service.NewBulkheadExternalService(breakerService, paymentBulkhead, notificationBulkhead)
+
+	// 最外层记录外部调用的跨度，包含隔离舱排队和熔断拒绝的耗时
+	externalService := service.NewTracingExternalService(bulkheadService)
+
+	var bulkheads []*bulkhead.Bulkhead
+	for _, b := range []*bulkhead.Bulkhead{paymentBulkhead, notificationBulkhead} {
+		if b != nil {
+			bulkheads = append(bulkheads, b)
+		}
+	}
+	bulkheadReporter := monitors.NewBulkheadReporter(monitor, 15*time.Second, bulkheads...)
+	bulkheadReporter.Start()
+
+	var breakers []*circuitbreaker.Breaker
+	for _, breaker := range []*circuitbreaker.Breaker{paymentBreaker, notificationBreaker} {
+		if breaker != nil {
+			breakers = append(breakers, breaker)
+		}
+	}
+
+	// 创建业务服务
+	svc := service.NewService(retryConfig, externalService,
+		service.WithPaymentRetryConfig(paymentRetryConfig),
+		service.WithNotificationRetryConfig(notificationRetryConfig),
+		service.WithMaxPendingNotifications(viper.GetInt("external_services.notification_service.max_pending")),
+		service.WithLogger(appLogger),
+	)
+
+	// 创建API处理器
+	handler := api.NewHandler(svc)
+
+	// 创建探针的健康检查组：存活只检查进程本身，就绪还检查依赖和关闭状态，启动检查初始化是否完成
+	startupCheck := healthcheck.NewToggleCheck("startup", false)
+	shutdownCheck := healthcheck.NewToggleCheck("shutdown", true)
+
+	checkTimeout := healthcheck.WithCheckTimeout(viper.GetDuration("healthcheck.check_timeout"))
+
+	livenessChecker := healthcheck.NewChecker(checkTimeout)
+	livenessChecker.AddCheck(healthcheck.NewCustomCheck("ping", func(ctx context.Context) (healthcheck.Status, error) {
+		return healthcheck.StatusUp, nil
+	}))
+
+	startupChecker := healthcheck.NewChecker(checkTimeout)
+	startupChecker.AddCheck(startupCheck)
+
+	// 只对就绪检查做抖动抑制，避免网络抖动导致实例被频繁摘除和恢复
+	readinessChecker := healthcheck.NewChecker(checkTimeout, healthcheck.WithDamping(loadDampingConfig("healthcheck.damping")))
+	readinessChecker.AddCheck(startupCheck)
+	readinessChecker.AddCheck(shutdownCheck)
+
+	// 添加依赖服务的HTTP健康检查，非关键依赖失败时只降级不摘除流量
+	if err := addDependencyChecks(readinessChecker); err != nil {
+		appLogger.Fatalf("Invalid health check configuration: %v", err)
+	}
+	statusCodes, err := loadStatusCodes()
+	if err != nil {
+		appLogger.Fatalf("Invalid health check configuration: %v", err)
+	}
+
+	// 依赖检查较慢，在后台定期执行，就绪探针返回缓存的结果
+	readinessChecker.Start(viper.GetDuration("healthcheck.check_interval"))
+
+	// 健康检查和指标端点不限流、不追踪
+	probePaths := []string{
+		viper.GetString("healthcheck.endpoint"),
+		viper.GetString("healthcheck.liveness_endpoint"),
+		viper.GetString("healthcheck.readiness_endpoint"),
+		viper.GetString("healthcheck.startup_endpoint"),
+		viper.GetString("healthcheck.history_endpoint"),
+		viper.GetString("monitoring.prometheus.endpoint"),
+	}
 
 	// 创建Gin路由
 	router := gin.New()
 
 	// 添加中间件
-	router.Use(gin.Recovery())
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.TracingMiddleware(probePaths...))
+	router.Use(middleware.RequestLoggerMiddleware(appLogger))
+	if viper.GetBool("logging.access_log.enabled") {
+		router.Use(middleware.AccessLogMiddleware(&middleware.AccessLogConfig{
+			SampleRate:    viper.GetFloat64("logging.access_log.sample_rate"),
+			SlowThreshold: viper.GetDuration("logging.access_log.slow_threshold"),
+			SkipPaths:     viper.GetStringSlice("logging.access_log.skip_paths"),
+		}, appLogger))
+	}
+	router.Use(gin.RecoveryWithWriter(appLogger.WriterLevel(logrus.ErrorLevel)))
 	router.Use(middleware.MonitoringMiddleware(monitor))
 	router.Use(middleware.ErrorMonitoring(monitor))
-	router.Use(middleware.RetryMiddleware(nil)) // 使用默认配置
+	if viper.GetBool("server.concurrency_limit.enabled") {
+		router.Use(middleware.ConcurrencyLimitMiddleware(&middleware.ConcurrencyLimitConfig{
+			InitialLimit:     viper.GetInt("server.concurrency_limit.initial_limit"),
+			MinLimit:         viper.GetInt("server.concurrency_limit.min_limit"),
+			MaxLimit:         viper.GetInt("server.concurrency_limit.max_limit"),
+			BackoffRatio:     viper.GetFloat64("server.concurrency_limit.backoff_ratio"),
+			LatencyThreshold: viper.GetDuration("server.concurrency_limit.latency_threshold"),
+			RetryAfter:       viper.GetDuration("server.concurrency_limit.retry_after"),
+			ExcludedPaths:    probePaths,
+		}, monitor))
+	}
+	if viper.GetBool("rate_limit.enabled") {
+		rateLimitConfig, err := loadRateLimitConfig()
+		if err != nil {
+			appLogger.Fatalf("Invalid rate limit configuration: %v", err)
+		}
+		router.Use(middleware.RateLimitMiddleware(rateLimitConfig, monitor))
+	}
+	timeoutConfig, err := loadTimeoutConfig()
+	if err != nil {
+		appLogger.Fatalf("Invalid timeout configuration: %v", err)
+	}
+	router.Use(middleware.TimeoutMiddleware(timeoutConfig, monitor))
+	if viper.GetBool("idempotency.enabled") {
+		idempotencyStore := idempotency.NewMemoryStore(viper.GetDuration("idempotency.ttl"), nil,
+			idempotency.WithMaxEntries(viper.GetInt("idempotency.max_entries")),
+		)
+		router.Use(middleware.IdempotencyMiddleware(&middleware.IdempotencyConfig{
+			Header:  viper.GetString("idempotency.header"),
+			Methods: viper.GetStringSlice("idempotency.methods"),
+			Store:   idempotencyStore,
+		}))
+	}
+	retryMiddlewareConfig, err := loadRetryMiddlewareConfig(retryBudget)
+	if err != nil {
+		appLogger.Fatalf("Invalid HTTP retry configuration: %v", err)
+	}
+	router.Use(middleware.RetryMiddleware(retryMiddlewareConfig)) // 必须最后注册，之后注册的中间件会使对应路由不重试
 
 	// 注册API路由
 	handler.RegisterRoutes(router)
 
 	// 注册健康检查和指标端点
-	router.GET("/health", middleware.HealthCheckHandler(monitor))
+	router.GET(viper.GetString("healthcheck.endpoint"), middleware.HealthCheckHandler(monitor, breakers...))
+	router.GET(viper.GetString("healthcheck.liveness_endpoint"), middleware.ProbeHandler(livenessChecker, statusCodes))
+	router.GET(viper.GetString("healthcheck.readiness_endpoint"), middleware.ProbeHandler(readinessChecker, statusCodes))
+	router.GET(viper.GetString("healthcheck.startup_endpoint"), middleware.ProbeHandler(startupChecker, statusCodes))
+	router.GET(viper.GetString("healthcheck.history_endpoint"), middleware.HealthHistoryHandler(readinessChecker, monitor))
 
 	// 启动HTTP服务器
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", viper.GetInt("server.port")),
-		Handler: router,
+		Addr:              fmt.Sprintf(":%d", viper.GetInt("server.port")),
+		Handler:           router,
+		ReadHeaderTimeout: viper.GetDuration("server.read_header_timeout"),
+		ReadTimeout:       viper.GetDuration("server.read_timeout"),
+		WriteTimeout:      viper.GetDuration("server.write_timeout"),
+		IdleTimeout:       viper.GetDuration("server.idle_timeout"),
 	}
 
 	// 优雅关闭
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			appLogger.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
+	// 初始化完成
+	startupCheck.SetUp()
+
 	// 等待中断信号优雅关闭服务器
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	appLogger.Info("Shutting down server...")
+
+	// 先将就绪状态置为DOWN，等待负载均衡器摘除本实例后再关闭服务器
+	shutdownCheck.SetDown("server is shutting down")
+	if delay := viper.GetDuration("server.shutdown_delay"); delay > 0 {
+		appLogger.Infof("Waiting %v for load balancers to drain traffic", delay)
+		time.Sleep(delay)
+	}
 
 	// 创建关闭上下文
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -127,12 +314,30 @@ func main() {
 
 	// 关闭HTTP服务器
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		appLogger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	// 关闭监控
 	if err := prometheusMonitor.StopServer(ctx); err != nil {
-		log.Printf("Error stopping Prometheus server: %v", err)
+		appLogger.Errorf("Error stopping Prometheus server: %v", err)
+	}
+
+	// 停止重试预算指标导出
+	if budgetReporter != nil {
+		budgetReporter.Stop()
+	}
+
+	// 停止后台健康检查
+	readinessChecker.Stop()
+
+	// 停止隔离舱指标导出
+	bulkheadReporter.Stop()
+
+	// 导出剩余的跨度
+	if tracerProvider != nil {
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			appLogger.Errorf("Error shutting down tracing: %v", err)
+		}
 	}
 
 	// 停止定期刷新
@@ -141,7 +346,7 @@ func main() {
 	// 最后一次刷新指标
 	monitors.FlushOnShutdown(loggingFallback)
 
-	log.Println("Server exited properly")
+	appLogger.Info("Server exited properly")
 }
 
 // 加载配置文件
@@ -155,18 +360,91 @@ func loadConfig() error {
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.mode", "release")
 	viper.SetDefault("server.timeout", "10s")
-
+	viper.SetDefault("server.read_header_timeout", "5s")
+	viper.SetDefault("server.read_timeout", "15s")
+	viper.SetDefault("server.write_timeout", "20s")
+	viper.SetDefault("server.idle_timeout", "60s")
+	viper.SetDefault("server.shutdown_delay", "0s")
+	viper.SetDefault("server.concurrency_limit.enabled", true)
+	viper.SetDefault("server.concurrency_limit.initial_limit", 100)
+	viper.SetDefault("server.concurrency_limit.min_limit", 10)
+	viper.SetDefault("server.concurrency_limit.max_limit", 1000)
+	viper.SetDefault("server.concurrency_limit.backoff_ratio", 0.9)
+	viper.SetDefault("server.concurrency_limit.latency_threshold", "500ms")
+	viper.SetDefault("server.concurrency_limit.retry_after", "1s")
+
+	viper.SetDefault("retry.strategy", retry.StrategyExponential)
 	viper.SetDefault("retry.max_attempts", 3)
 	viper.SetDefault("retry.initial_interval", "100ms")
 	viper.SetDefault("retry.max_interval", "1s")
 	viper.SetDefault("retry.multiplier", 2.0)
 	viper.SetDefault("retry.randomization_factor", 0.5)
+	viper.SetDefault("retry.per_attempt_timeout", "0s")
+	viper.SetDefault("retry.min_attempt_time", "50ms")
+	viper.SetDefault("retry.budget.enabled", true)
+	viper.SetDefault("retry.budget.ratio", 0.1)
+	viper.SetDefault("retry.budget.min_retries_per_second", 5)
+	viper.SetDefault("retry.budget.max_tokens", 100)
+
+	viper.SetDefault("rate_limit.enabled", false)
+	viper.SetDefault("rate_limit.key", "ip")
+	viper.SetDefault("rate_limit.api_key_header", "X-API-Key")
+
+	viper.SetDefault("idempotency.enabled", true)
+	viper.SetDefault("idempotency.header", "Idempotency-Key")
+	viper.SetDefault("idempotency.methods", []string{"POST", "PATCH"})
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.max_entries", idempotency.DefaultMaxEntries)
+
+	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("logging.format", "json")
+	viper.SetDefault("logging.output", "stdout")
+	viper.SetDefault("logging.file", "logs/app.log")
+	viper.SetDefault("logging.rotation.max_size_mb", 100)
+	viper.SetDefault("logging.rotation.interval", "24h")
+	viper.SetDefault("logging.rotation.max_backups", 7)
+	viper.SetDefault("logging.rotation.max_age", "168h")
+	viper.SetDefault("logging.access_log.enabled", true)
+	viper.SetDefault("logging.access_log.sample_rate", 1.0)
+	viper.SetDefault("logging.access_log.slow_threshold", "1s")
+	viper.SetDefault("logging.access_log.skip_paths", []string{"/health", "/livez", "/readyz", "/startupz", "/internal/health/history", "/metrics"})
 
 	viper.SetDefault("monitoring.prometheus.enabled", true)
 	viper.SetDefault("monitoring.prometheus.endpoint", "/metrics")
 	viper.SetDefault("monitoring.fallback.enabled", true)
 	viper.SetDefault("monitoring.fallback.local_logging", true)
 	viper.SetDefault("monitoring.fallback.periodic_check", "30s")
+	viper.SetDefault("monitoring.fallback.damping.rise_threshold", 2)
+	viper.SetDefault("monitoring.fallback.damping.fall_threshold", 3)
+	viper.SetDefault("monitoring.fallback.damping.min_hold_time", "0s")
+	viper.SetDefault("monitoring.fallback.damping.history_size", 50)
+
+	viper.SetDefault("external_services.notification_service.max_pending", 100)
+
+	viper.SetDefault("healthcheck.endpoint", "/health")
+	viper.SetDefault("healthcheck.liveness_endpoint", "/livez")
+	viper.SetDefault("healthcheck.readiness_endpoint", "/readyz")
+	viper.SetDefault("healthcheck.startup_endpoint", "/startupz")
+	viper.SetDefault("healthcheck.check_interval", "5s")
+	viper.SetDefault("healthcheck.check_timeout", "3s")
+	viper.SetDefault("healthcheck.history_endpoint", "/internal/health/history")
+	viper.SetDefault("healthcheck.damping.rise_threshold", 2)
+	viper.SetDefault("healthcheck.damping.fall_threshold", 3)
+	viper.SetDefault("healthcheck.damping.min_hold_time", "10s")
+	viper.SetDefault("healthcheck.damping.history_size", 50)
+	viper.SetDefault("healthcheck.dependencies", []map[string]interface{}{
+		{"name": "payment-service", "url": "http://payment-service:8080/health", "timeout": "5s", "critical": true},
+	})
+
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.service_name", "high-availability-system")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("tracing.exporter", "otlp")
+	viper.SetDefault("tracing.otlp.endpoint", "http://localhost:4318/v1/traces")
+	viper.SetDefault("tracing.otlp.timeout", "5s")
+	viper.SetDefault("tracing.file", "logs/traces.log")
+	viper.SetDefault("tracing.fallback.enabled", true)
+	viper.SetDefault("tracing.fallback.file", "logs/traces-fallback.log")
 
 	// 读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
@@ -174,8 +452,377 @@ func loadConfig() error {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return err
 		}
-		log.Println("Config file not found, using defaults")
 	}
 
 	return nil
 }
+
+// 从配置中读取重试配置，未设置的字段沿用 base 中的值
+func loadRetryConfig(key string, base *retry.Config, retryBudget *retry.Budget) (*retry.Config, error) {
+	config := &retry.Config{}
+	strategy := viper.GetString("retry.strategy")
+	if base != nil {
+		*config = *base
+		config.Backoff = nil
+	}
+
+	if viper.IsSet(key + ".max_attempts") {
+		config.MaxAttempts = viper.GetInt(key + ".max_attempts")
+	}
+	if viper.IsSet(key + ".initial_interval") {
+		config.InitialInterval = viper.GetDuration(key + ".initial_interval")
+	}
+	if viper.IsSet(key + ".max_interval") {
+		config.MaxInterval = viper.GetDuration(key + ".max_interval")
+	}
+	if viper.IsSet(key + ".multiplier") {
+		config.Multiplier = viper.GetFloat64(key + ".multiplier")
+	}
+	if viper.IsSet(key + ".randomization_factor") {
+		config.RandomizationFactor = viper.GetFloat64(key + ".randomization_factor")
+	}
+	if viper.IsSet(key + ".per_attempt_timeout") {
+		config.PerAttemptTimeout = viper.GetDuration(key + ".per_attempt_timeout")
+	}
+	if viper.IsSet(key + ".min_attempt_time") {
+		config.MinAttemptTime = viper.GetDuration(key + ".min_attempt_time")
+	}
+	if viper.IsSet(key + ".strategy") {
+		strategy = viper.GetString(key + ".strategy")
+	}
+
+	config.Budget = retryBudget
+
+	backoff, err := retry.NewBackoff(strategy, config)
+	if err != nil {
+		return nil, err
+	}
+	config.Backoff = backoff
+
+	return config, nil
+}
+
+// 从配置中创建熔断器，未启用时返回nil
+func loadCircuitBreaker(key, name string, metrics *monitors.CircuitBreakerMetrics) *circuitbreaker.Breaker {
+	if !viper.GetBool(key + ".enabled") {
+		return nil
+	}
+
+	config := circuitbreaker.DefaultConfig(name)
+	if viper.IsSet(key + ".failure_rate_threshold") {
+		config.FailureRateThreshold = viper.GetFloat64(key + ".failure_rate_threshold")
+	}
+	if viper.IsSet(key + ".min_requests") {
+		config.MinRequests = viper.GetInt(key + ".min_requests")
+	}
+	if viper.IsSet(key + ".window") {
+		config.Window = viper.GetDuration(key + ".window")
+	}
+	if viper.IsSet(key + ".window_buckets") {
+		config.WindowBuckets = viper.GetInt(key + ".window_buckets")
+	}
+	if viper.IsSet(key + ".consecutive_failures") {
+		config.ConsecutiveFailures = viper.GetInt(key + ".consecutive_failures")
+	}
+	if viper.IsSet(key + ".open_timeout") {
+		config.OpenTimeout = viper.GetDuration(key + ".open_timeout")
+	}
+	if viper.IsSet(key + ".half_open_max_probes") {
+		config.HalfOpenMaxProbes = viper.GetInt(key + ".half_open_max_probes")
+	}
+	config.IsFailure = service.IsExternalFailure
+	config.OnStateChange = metrics.OnStateChange
+
+	metrics.Init(name)
+	return circuitbreaker.New(config)
+}
+
+// 从配置中创建隔离舱，未启用时返回nil
+func loadBulkhead(key, name string) *bulkhead.Bulkhead {
+	if !viper.GetBool(key + ".enabled") {
+		return nil
+	}
+
+	return bulkhead.New(bulkhead.Config{
+		Name:          name,
+		MaxConcurrent: viper.GetInt(key + ".max_concurrent"),
+		MaxQueue:      viper.GetInt(key + ".max_queue"),
+		QueueTimeout:  viper.GetDuration(key + ".queue_timeout"),
+	})
+}
+
+// 从配置中读取限流配置
+func loadRateLimitConfig() (*middleware.RateLimitConfig, error) {
+	config := &middleware.RateLimitConfig{}
+	if err := viper.UnmarshalKey("rate_limit.routes", &config.Rules); err != nil {
+		return nil, err
+	}
+
+	switch key := viper.GetString("rate_limit.key"); key {
+	case "ip":
+		config.KeyFunc = middleware.RateLimitKeyByIP
+	case "api_key":
+		// 只对配置中登记的API Key单独限流，其余请求按IP限流
+		apiKeys := make(map[string]bool)
+		for _, apiKey := range viper.GetStringSlice("rate_limit.api_keys") {
+			apiKeys[apiKey] = true
+		}
+		if len(apiKeys) == 0 {
+			return nil, fmt.Errorf("rate limit key api_key requires rate_limit.api_keys")
+		}
+		config.KeyFunc = middleware.RateLimitKeyByAPIKey(viper.GetString("rate_limit.api_key_header"), func(key string) bool {
+			return apiKeys[key]
+		})
+	default:
+		return nil, fmt.Errorf("unknown rate limit key: %s", key)
+	}
+
+	return config, nil
+}
+
+// dependencyCheckConfig 是配置文件中单个依赖服务的健康检查
+type dependencyCheckConfig struct {
+	Name     string        `mapstructure:"name"`
+	URL      string        `mapstructure:"url"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Critical *bool         `mapstructure:"critical"` // 未设置时为关键依赖
+}
+
+// 从配置中创建重试预算，prefix 为配置节的路径，未启用时返回nil
+func loadBudget(prefix string) *retry.Budget {
+	if !viper.GetBool(prefix + ".enabled") {
+		return nil
+	}
+	return retry.NewBudget(retry.BudgetConfig{
+		Ratio:               viper.GetFloat64(prefix + ".ratio"),
+		MinRetriesPerSecond: viper.GetFloat64(prefix + ".min_retries_per_second"),
+		MaxTokens:           viper.GetFloat64(prefix + ".max_tokens"),
+	})
+}
+
+// 从配置中为每个依赖服务添加HTTP健康检查，启用对冲时以对冲方式执行
+//
+// 对冲使用独立的预算，后台探测流量不计入业务重试预算，也不会影响 retry_budget 指标
+func addDependencyChecks(checker *healthcheck.Checker) error {
+	var dependencies []dependencyCheckConfig
+	if err := viper.UnmarshalKey("healthcheck.dependencies", &dependencies); err != nil {
+		return err
+	}
+	hedgeBudget := loadBudget("healthcheck.hedge.budget")
+
+	for _, dependency := range dependencies {
+		if dependency.Name == "" || dependency.URL == "" {
+			return fmt.Errorf("dependency check requires name and url")
+		}
+
+		var check healthcheck.Check = healthcheck.NewHTTPCheck(dependency.Name, dependency.URL, dependency.Timeout)
+		if viper.GetBool("healthcheck.hedge.enabled") {
+			check = healthcheck.NewHedgedCheck(check, &retry.HedgeConfig{
+				Delay:     viper.GetDuration("healthcheck.hedge.delay"),
+				MaxHedges: viper.GetInt("healthcheck.hedge.max_hedges"),
+				Budget:    hedgeBudget,
+			})
+		}
+
+		criticality := healthcheck.Critical
+		if dependency.Critical != nil && !*dependency.Critical {
+			criticality = healthcheck.NonCritical
+		}
+		checker.AddCheckWithCriticality(check, criticality)
+	}
+
+	return nil
+}
+
+// 从配置中读取健康状态的抖动抑制配置，prefix 为配置节的路径
+func loadDampingConfig(prefix string) healthcheck.DampingConfig {
+	return healthcheck.DampingConfig{
+		RiseThreshold: viper.GetInt(prefix + ".rise_threshold"),
+		FallThreshold: viper.GetInt(prefix + ".fall_threshold"),
+		MinHoldTime:   viper.GetDuration(prefix + ".min_hold_time"),
+		HistorySize:   viper.GetInt(prefix + ".history_size"),
+	}
+}
+
+// 从配置中读取健康状态到HTTP状态码的映射，未配置的状态使用默认值
+func loadStatusCodes() (map[healthcheck.Status]int, error) {
+	statusCodes := healthcheck.DefaultStatusCodes()
+	// viper 的键不区分大小写，统一转换为大写的状态名
+	for key := range viper.GetStringMap("healthcheck.status_codes") {
+		code := viper.GetInt("healthcheck.status_codes." + key)
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code for health status %s", key)
+		}
+		statusCodes[healthcheck.Status(strings.ToUpper(key))] = code
+	}
+	return statusCodes, nil
+}
+
+// 从配置中读取请求超时中间件的配置
+func loadTimeoutConfig() (*middleware.TimeoutConfig, error) {
+	config := &middleware.TimeoutConfig{
+		Timeout: viper.GetDuration("server.timeout"),
+	}
+	if err := viper.UnmarshalKey("server.timeout_routes", &config.Routes); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// retryPolicyConfig 是配置文件中单个路由的重试策略
+type retryPolicyConfig struct {
+	Method          string        `mapstructure:"method"`
+	Path            string        `mapstructure:"path"`
+	Enabled         *bool         `mapstructure:"enabled"`
+	MaxAttempts     int           `mapstructure:"max_attempts"`
+	RetryableStatus []int         `mapstructure:"retryable_status"`
+	Strategy        string        `mapstructure:"strategy"`
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+	MaxInterval     time.Duration `mapstructure:"max_interval"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+	Idempotent      bool          `mapstructure:"idempotent"`
+}
+
+// 从配置中读取重试中间件的配置
+func loadRetryMiddlewareConfig(retryBudget *retry.Budget) (*middleware.RetryConfig, error) {
+	config := middleware.DefaultRetryConfig()
+	config.Budget = retryBudget
+
+	if !viper.IsSet("http_retry") {
+		return config, nil
+	}
+
+	baseRetryConfig, err := loadRetryConfig("http_retry", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	config.MaxAttempts = baseRetryConfig.MaxAttempts
+	config.InitialInterval = baseRetryConfig.InitialInterval
+	config.MaxInterval = baseRetryConfig.MaxInterval
+	config.Multiplier = baseRetryConfig.Multiplier
+	config.RandomFactor = baseRetryConfig.RandomizationFactor
+	if viper.IsSet("http_retry.strategy") {
+		config.Backoff = baseRetryConfig.Backoff
+	}
+
+	if viper.IsSet("http_retry.retryable_status") {
+		config.RetryableStatus = viper.GetIntSlice("http_retry.retryable_status")
+	}
+	if viper.IsSet("http_retry.retryable_methods") {
+		config.RetryableMethods = viper.GetStringSlice("http_retry.retryable_methods")
+	}
+	config.Timeout = viper.GetDuration("http_retry.timeout")
+
+	if viper.IsSet("http_retry.routes") {
+		var routes []retryPolicyConfig
+		if err := viper.UnmarshalKey("http_retry.routes", &routes); err != nil {
+			return nil, err
+		}
+
+		config.Policies = make([]middleware.RetryPolicy, 0, len(routes))
+		for _, route := range routes {
+			policy := middleware.RetryPolicy{
+				Method:          route.Method,
+				Path:            route.Path,
+				Enabled:         route.Enabled == nil || *route.Enabled,
+				MaxAttempts:     route.MaxAttempts,
+				RetryableStatus: route.RetryableStatus,
+				Timeout:         route.Timeout,
+				Idempotent:      route.Idempotent,
+			}
+
+			// 路由单独配置了退避参数时创建独立的退避策略
+			if route.Strategy != "" || route.InitialInterval > 0 || route.MaxInterval > 0 {
+				backoffConfig := *baseRetryConfig
+				if route.InitialInterval > 0 {
+					backoffConfig.InitialInterval = route.InitialInterval
+				}
+				if route.MaxInterval > 0 {
+					backoffConfig.MaxInterval = route.MaxInterval
+				}
+				strategy := route.Strategy
+				if strategy == "" {
+					strategy = viper.GetString("http_retry.strategy")
+				}
+				policy.Backoff, err = retry.NewBackoff(strategy, &backoffConfig)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			config.Policies = append(config.Policies, policy)
+		}
+	}
+
+	return config, nil
+}
+
+// 从配置中创建追踪提供者，未启用时返回nil
+func loadTracerProvider(appLogger *logrus.Logger) (*tracing.Provider, error) {
+	if !viper.GetBool("tracing.enabled") {
+		return nil, nil
+	}
+
+	var exporter tracing.Exporter
+	switch viper.GetString("tracing.exporter") {
+	case "otlp":
+		exporter = tracing.NewOTLPExporter(
+			viper.GetString("tracing.otlp.endpoint"),
+			viper.GetDuration("tracing.otlp.timeout"),
+			viper.GetStringMapString("tracing.otlp.headers"),
+		)
+	case "stdout":
+		exporter = tracing.NewStdoutExporter()
+	case "file":
+		fileExporter, err := tracing.NewFileExporter(viper.GetString("tracing.file"))
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", viper.GetString("tracing.exporter"))
+	}
+
+	// 主导出器失败时写入本地文件，文件不可用时直接丢弃
+	var fallback tracing.Exporter
+	if viper.GetBool("tracing.fallback.enabled") {
+		fileExporter, err := tracing.NewFileExporter(viper.GetString("tracing.fallback.file"))
+		if err != nil {
+			appLogger.Warnf("Failed to open tracing fallback file, spans will be dropped when export fails: %v", err)
+		} else {
+			fallback = fileExporter
+		}
+	}
+
+	batchConfig := tracing.DefaultBatchConfig()
+	batchConfig.Logger = appLogger
+	if viper.IsSet("tracing.batch.queue_size") {
+		batchConfig.QueueSize = viper.GetInt("tracing.batch.queue_size")
+	}
+	if viper.IsSet("tracing.batch.batch_size") {
+		batchConfig.BatchSize = viper.GetInt("tracing.batch.batch_size")
+	}
+	if viper.IsSet("tracing.batch.flush_interval") {
+		batchConfig.FlushInterval = viper.GetDuration("tracing.batch.flush_interval")
+	}
+
+	return tracing.NewProvider(tracing.ProviderConfig{
+		ServiceName: viper.GetString("tracing.service_name"),
+		SampleRatio: viper.GetFloat64("tracing.sample_ratio"),
+		Processor:   tracing.NewBatchProcessor(exporter, fallback, batchConfig),
+	}), nil
+}
+
+// 从配置中创建日志器
+func loadLogger() (*logrus.Logger, error) {
+	return logger.New(logger.Config{
+		Level:          viper.GetString("logging.level"),
+		Format:         viper.GetString("logging.format"),
+		Output:         viper.GetString("logging.output"),
+		File:           viper.GetString("logging.file"),
+		MaxSize:        viper.GetInt64("logging.rotation.max_size_mb") * 1024 * 1024,
+		RotateInterval: viper.GetDuration("logging.rotation.interval"),
+		MaxBackups:     viper.GetInt("logging.rotation.max_backups"),
+		MaxAge:         viper.GetDuration("logging.rotation.max_age"),
+	})
+}
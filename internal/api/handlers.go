@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/saixiaoxi/high-availability-system/internal/models"
 	"github.com/saixiaoxi/high-availability-system/internal/service"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
 )
 
 // Handler 封装所有API处理函数
@@ -47,14 +49,17 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 	}
 }
 
+// respondError 返回带有请求ID的错误响应
+func respondError(c *gin.Context, status int, errMsg string) {
+	c.JSON(status, models.NewErrorResponse(errMsg).WithRequestID(requestid.FromContext(c.Request.Context())))
+}
+
 // GetProducts 获取所有产品
 func (h *Handler) GetProducts(c *gin.Context) {
 	// 从服务层获取产品
 	products, err := h.service.GetAllProducts(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve products",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to retrieve products")
 		return
 	}
 
@@ -68,9 +73,7 @@ func (h *Handler) GetProduct(c *gin.Context) {
 	// 从服务层获取产品
 	product, err := h.service.GetProductByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Product with ID %s not found", id),
-		})
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Product with ID %s not found", id))
 		return
 	}
 
@@ -83,9 +86,7 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 
 	// 绑定JSON请求体
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid product data",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid product data")
 		return
 	}
 
@@ -95,9 +96,7 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 	// 调用服务层创建产品
 	createdProduct, err := h.service.CreateProduct(c.Request.Context(), product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create product",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to create product")
 		return
 	}
 
@@ -111,9 +110,7 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 
 	// 绑定JSON请求体
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid product data",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid product data")
 		return
 	}
 
@@ -124,9 +121,7 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 	// 调用服务层更新产品
 	updatedProduct, err := h.service.UpdateProduct(c.Request.Context(), product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update product",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to update product")
 		return
 	}
 
@@ -140,9 +135,7 @@ func (h *Handler) DeleteProduct(c *gin.Context) {
 	// 调用服务层删除产品
 	err := h.service.DeleteProduct(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete product",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to delete product")
 		return
 	}
 
@@ -156,9 +149,7 @@ func (h *Handler) GetOrders(c *gin.Context) {
 	// 从服务层获取订单
 	orders, err := h.service.GetAllOrders(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve orders",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to retrieve orders")
 		return
 	}
 
@@ -172,9 +163,7 @@ func (h *Handler) GetOrder(c *gin.Context) {
 	// 从服务层获取订单
 	order, err := h.service.GetOrderByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Order with ID %s not found", id),
-		})
+		respondError(c, http.StatusNotFound, fmt.Sprintf("Order with ID %s not found", id))
 		return
 	}
 
@@ -187,9 +176,7 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 
 	// 绑定JSON请求体
 	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid order data",
-		})
+		respondError(c, http.StatusBadRequest, "Invalid order data")
 		return
 	}
 
@@ -198,10 +185,13 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 
 	// 调用服务层创建订单
 	createdOrder, err := h.service.CreateOrder(c.Request.Context(), order)
+	if errors.Is(err, service.ErrPaymentDeclined) {
+		// 业务拒绝返回4xx，避免被重试中间件重试
+		respondError(c, http.StatusPaymentRequired, "Payment declined")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create order",
-		})
+		respondError(c, http.StatusInternalServerError, "Failed to create order")
 		return
 	}
 
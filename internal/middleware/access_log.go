@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLogConfig 定义访问日志中间件的配置
+type AccessLogConfig struct {
+	SampleRate    float64       // 成功请求的采样比例 (0-1)，错误和慢请求始终记录
+	SlowThreshold time.Duration // 超过该延迟的请求视为慢请求，为0时不判断
+	SkipPaths     []string      // 不记录的路径，如健康检查
+	Rand          clock.Rand    // 采样使用的随机数来源，为nil时使用全局随机源
+	Clock         clock.Clock   // 测量请求延迟的时钟，为nil时使用系统时钟
+}
+
+// DefaultAccessLogConfig 返回默认的访问日志配置
+func DefaultAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		SampleRate:    1.0,
+		SlowThreshold: time.Second,
+		SkipPaths:     []string{"/health", "/livez", "/readyz", "/startupz", "/metrics"},
+	}
+}
+
+// AccessLogMiddleware 创建访问日志中间件，每个请求结束后写一行结构化日志
+//
+// 日志通过请求级日志条目写入，自动带上请求ID和追踪ID。应注册在请求日志
+// 中间件之后、其他中间件之前，以便记录被限流等中间件拒绝的请求
+func AccessLogMiddleware(config *AccessLogConfig, log *logrus.Logger) gin.HandlerFunc {
+	// 如果没有提供配置，使用默认配置
+	if config == nil {
+		config = DefaultAccessLogConfig()
+	}
+
+	rnd := config.Rand
+	if rnd == nil {
+		rnd = clock.GlobalRand()
+	}
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	skip := make(map[string]bool, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		start := clk.Now()
+		bytesIn := c.Request.ContentLength
+
+		c.Next()
+
+		latency := clk.Since(start)
+		status := c.Writer.Status()
+		failed := status >= http.StatusInternalServerError || len(c.Errors) > 0
+		slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+
+		// 成功且不慢的请求按比例采样
+		if !failed && !slow && status < http.StatusBadRequest && rnd.Float64() >= config.SampleRate {
+			return
+		}
+
+		if bytesIn < 0 {
+			bytesIn = 0
+		}
+		bytesOut := c.Writer.Size()
+		if bytesOut < 0 {
+			bytesOut = 0
+		}
+
+		fields := logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"path":       c.Request.URL.Path,
+			"status":     status,
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+			"bytes_in":   bytesIn,
+			"bytes_out":  bytesOut,
+			"client_ip":  c.ClientIP(),
+		}
+		if attempts, ok := c.Get(RetryAttemptsKey); ok {
+			fields["retry_attempts"] = attempts
+		}
+		if len(c.Errors) > 0 {
+			fields["error"] = strings.Join(c.Errors.Errors(), "; ")
+		}
+		if slow {
+			fields["slow"] = true
+		}
+
+		entry := logger.FromContext(c.Request.Context(), log).WithFields(fields)
+		switch {
+		case failed:
+			entry.Error("Request completed")
+		case slow || status >= http.StatusBadRequest:
+			entry.Warn("Request completed")
+		default:
+			entry.Info("Request completed")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/sirupsen/logrus"
+)
+
+// sequenceRand 依次返回 values 中的值
+type sequenceRand struct {
+	values []float64
+	next   int
+}
+
+func (r *sequenceRand) Float64() float64 {
+	value := r.values[r.next%len(r.values)]
+	r.next++
+	return value
+}
+
+// accessLogRouter 创建只带访问日志中间件的路由，返回写入的日志
+func accessLogRouter(config *AccessLogConfig) (*gin.Engine, *bytes.Buffer) {
+	var output bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&output)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	router := gin.New()
+	router.Use(AccessLogMiddleware(config, log))
+	return router, &output
+}
+
+// accessLogLines 解析日志中的每一行
+func accessLogLines(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("invalid log line %q", line)
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		rand       []float64
+		want       int
+	}{
+		{"log all", 1, []float64{0, 0.5, 0.99}, 3},
+		{"log none", 0, []float64{0, 0.5, 0.99}, 0},
+		{"sample below rate", 0.5, []float64{0.1, 0.5, 0.7, 0.49}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, output := accessLogRouter(&AccessLogConfig{
+				SampleRate: tt.sampleRate,
+				Rand:       &sequenceRand{values: tt.rand},
+			})
+			router.GET("/ok", func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			for range tt.rand {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+			}
+			if got := len(accessLogLines(t, output)); got != tt.want {
+				t.Fatalf("%d requests logged, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessLogAlwaysLogsErrorsAndSlowRequests(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	router, output := accessLogRouter(&AccessLogConfig{
+		SampleRate:    0,
+		SlowThreshold: time.Second,
+		Rand:          &sequenceRand{values: []float64{0.5}},
+		Clock:         fake,
+	})
+	router.GET("/fail", func(c *gin.Context) {
+		c.String(http.StatusInternalServerError, "boom")
+	})
+	router.GET("/error", func(c *gin.Context) {
+		_ = c.Error(http.ErrHandlerTimeout)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/bad", func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+	router.GET("/slow", func(c *gin.Context) {
+		fake.Advance(2 * time.Second)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		path  string
+		level string
+	}{
+		{"/fail", "error"},
+		{"/error", "error"},
+		{"/bad", "warning"},
+		{"/slow", "warning"},
+		{"/fast", ""},
+	}
+
+	for _, tt := range tests {
+		output.Reset()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		lines := accessLogLines(t, output)
+		if tt.level == "" {
+			if len(lines) != 0 {
+				t.Errorf("%s: sampled out request was logged", tt.path)
+			}
+			continue
+		}
+		if len(lines) != 1 {
+			t.Fatalf("%s: %d lines, want 1", tt.path, len(lines))
+		}
+		if lines[0]["level"] != tt.level || lines[0]["path"] != tt.path {
+			t.Errorf("%s: got %v, want level %s", tt.path, lines[0], tt.level)
+		}
+	}
+
+	output.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if line := accessLogLines(t, output)[0]; line["slow"] != true || line["latency_ms"] != float64(2000) {
+		t.Errorf("slow request logged as %v", line)
+	}
+}
+
+func TestAccessLogSkipPaths(t *testing.T) {
+	router, output := accessLogRouter(&AccessLogConfig{
+		SampleRate: 1,
+		SkipPaths:  []string{"/livez"},
+	})
+	router.GET("/livez", func(c *gin.Context) {
+		c.Status(http.StatusServiceUnavailable)
+	})
+	router.GET("/api", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// 跳过的路径即使失败也不记录
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	lines := accessLogLines(t, output)
+	if len(lines) != 1 || lines[0]["path"] != "/api" {
+		t.Fatalf("got %v, want only /api logged", lines)
+	}
+}
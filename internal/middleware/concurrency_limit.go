@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// ConcurrencyLimitConfig 定义自适应并发限制中间件的配置
+type ConcurrencyLimitConfig struct {
+	InitialLimit     int           // 初始并发上限
+	MinLimit         int           // 并发上限的下限
+	MaxLimit         int           // 并发上限的上限
+	BackoffRatio     float64       // 出现慢请求或错误时上限乘以该比例 (0-1)
+	LatencyThreshold time.Duration // 超过该延迟的请求视为过载信号
+	RetryAfter       time.Duration // 拒绝请求时 Retry-After 头的值
+	ExcludedPaths    []string      // 不受限制的路径，如健康检查
+	Clock            clock.Clock   // 测量请求延迟的时钟，为nil时使用系统时钟
+}
+
+// DefaultConcurrencyLimitConfig 返回默认的并发限制配置
+func DefaultConcurrencyLimitConfig() *ConcurrencyLimitConfig {
+	return &ConcurrencyLimitConfig{
+		InitialLimit:     100,
+		MinLimit:         10,
+		MaxLimit:         1000,
+		BackoffRatio:     0.9,
+		LatencyThreshold: 500 * time.Millisecond,
+		RetryAfter:       time.Second,
+		ExcludedPaths:    []string{"/health", "/metrics"},
+	}
+}
+
+// aimdLimiter 使用加性增、乘性减（AIMD）算法根据延迟和错误调整并发上限
+//
+// 与TCP拥塞控制一样，每个往返周期最多减小一次：只有在上次减小之后才开始的
+// 请求才能触发下一次减小，同一批慢请求只会使上限减小一次
+type aimdLimiter struct {
+	config       *ConcurrencyLimitConfig
+	limit        float64
+	inFlight     int
+	lastDecrease time.Time
+	mutex        sync.Mutex
+}
+
+// newAIMDLimiter 创建AIMD限制器
+func newAIMDLimiter(config *ConcurrencyLimitConfig) *aimdLimiter {
+	return &aimdLimiter{
+		config: config,
+		limit:  float64(config.InitialLimit),
+	}
+}
+
+// acquire 尝试占用一个并发名额
+func (l *aimdLimiter) acquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release 归还名额并根据请求结果调整上限，返回调整后的上限和当前并发数
+func (l *aimdLimiter) release(start, end time.Time, failed bool) (int, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 过载信号时乘性减，上次减小之前开始的请求反映的是旧上限下的负载，不再重复减小
+	if failed || end.Sub(start) > l.config.LatencyThreshold {
+		if start.After(l.lastDecrease) {
+			l.limit = math.Max(float64(l.config.MinLimit), l.limit*l.config.BackoffRatio)
+			l.lastDecrease = end
+		}
+	} else if l.inFlight*2 >= int(l.limit) {
+		// 只有在上限被充分使用时才加性增，避免空闲时无限增长
+		l.limit = math.Min(float64(l.config.MaxLimit), l.limit+1)
+	}
+
+	l.inFlight--
+	return int(l.limit), l.inFlight
+}
+
+// snapshot 返回当前上限和并发数
+func (l *aimdLimiter) snapshot() (int, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit), l.inFlight
+}
+
+// ConcurrencyLimitMiddleware 创建自适应并发限制中间件
+//
+// 超过并发上限的请求返回503和 Retry-After 头；
+// 当前上限和并发数通过 concurrency_limit、concurrency_in_flight 指标导出
+func ConcurrencyLimitMiddleware(config *ConcurrencyLimitConfig, monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
+	// 如果没有提供配置，使用默认配置
+	if config == nil {
+		config = DefaultConcurrencyLimitConfig()
+	}
+
+	limiter := newAIMDLimiter(config)
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(config.RetryAfter.Seconds())))
+
+	excluded := make(map[string]bool, len(config.ExcludedPaths))
+	for _, path := range config.ExcludedPaths {
+		excluded[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if excluded[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		if !limiter.acquire() {
+			limit, inFlight := limiter.snapshot()
+			go recordConcurrency(monitor, limit, inFlight, true)
+
+			c.Header("Retry-After", retryAfter)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorResponse(c, "Server is overloaded, please retry later"))
+			return
+		}
+
+		// 使用defer确保处理器panic时也能归还名额
+		start := clk.Now()
+		defer func() {
+			failed := c.Writer.Status() >= http.StatusInternalServerError
+			limit, inFlight := limiter.release(start, clk.Now(), failed)
+			go recordConcurrency(monitor, limit, inFlight, false)
+		}()
+
+		c.Next()
+	}
+}
+
+// recordConcurrency 导出并发限制指标
+func recordConcurrency(monitor *monitors.MonitorWithFallback, limit, inFlight int, rejected bool) {
+	ctx := context.Background()
+
+	_ = monitor.Gauge(ctx, "concurrency_limit", float64(limit), nil)
+	_ = monitor.Gauge(ctx, "concurrency_in_flight", float64(inFlight), nil)
+	if rejected {
+		_ = monitor.Counter(ctx, "concurrency_limit_rejections_total", 1, nil)
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter() *aimdLimiter {
+	return newAIMDLimiter(&ConcurrencyLimitConfig{
+		InitialLimit:     100,
+		MinLimit:         10,
+		MaxLimit:         1000,
+		BackoffRatio:     0.5,
+		LatencyThreshold: 500 * time.Millisecond,
+	})
+}
+
+func TestAIMDLimiterDecreasesOncePerBurst(t *testing.T) {
+	l := newTestLimiter()
+	start := time.Unix(0, 0)
+
+	// 同时开始的100个慢请求只减小一次
+	for i := 0; i < 100; i++ {
+		if !l.acquire() {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		l.release(start, start.Add(time.Second+time.Duration(i)*time.Millisecond), false)
+	}
+	if limit, _ := l.snapshot(); limit != 50 {
+		t.Fatalf("limit %d after one slow burst, want 50", limit)
+	}
+
+	// 上次减小之后开始的慢请求可以再次减小
+	later := start.Add(2 * time.Second)
+	l.acquire()
+	l.release(later, later.Add(time.Second), true)
+	if limit, _ := l.snapshot(); limit != 25 {
+		t.Fatalf("limit %d after a new overload window, want 25", limit)
+	}
+}
+
+func TestAIMDLimiterRespectsMinLimit(t *testing.T) {
+	l := newTestLimiter()
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 10; i++ {
+		l.acquire()
+		l.release(now, now.Add(time.Second), true)
+		now = now.Add(2 * time.Second)
+	}
+	if limit, _ := l.snapshot(); limit != 10 {
+		t.Fatalf("limit %d, want MinLimit 10", limit)
+	}
+}
+
+func TestAIMDLimiterIncreasesOnlyWhenUtilized(t *testing.T) {
+	l := newTestLimiter()
+	now := time.Unix(0, 0)
+
+	// 并发数远低于上限时不增加
+	l.acquire()
+	l.release(now, now.Add(time.Millisecond), false)
+	if limit, _ := l.snapshot(); limit != 100 {
+		t.Fatalf("limit %d while idle, want 100", limit)
+	}
+
+	for i := 0; i < 60; i++ {
+		l.acquire()
+	}
+	l.release(now, now.Add(time.Millisecond), false)
+	if limit, _ := l.snapshot(); limit != 101 {
+		t.Fatalf("limit %d while utilized, want 101", limit)
+	}
+}
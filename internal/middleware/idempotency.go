@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+)
+
+// IdempotentReplayedHeader 标记响应是对之前请求结果的重放
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyConfig 定义幂等键中间件的配置
+type IdempotencyConfig struct {
+	Header  string                   // 幂等键请求头
+	Methods []string                 // 需要幂等处理的方法
+	Store   *idempotency.MemoryStore // 幂等键存储
+}
+
+// IdempotencyMiddleware 创建幂等键中间件
+//
+// 携带幂等键的请求第一次执行后保存其响应，重复的请求直接重放该响应；
+// 同一个键用于内容不同的请求时返回409；重复请求在第一个请求完成前会等待。
+// 5xx响应不会被保存，客户端可以使用同一个键重试
+func IdempotencyMiddleware(config *IdempotencyConfig) gin.HandlerFunc {
+	methods := make(map[string]bool, len(config.Methods))
+	for _, method := range config.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(config.Header)
+		if key == "" || !methods[c.Request.Method] {
+			c.Next()
+			return
+		}
+
+		// 读取请求体计算指纹，并重置以便后面的处理器读取
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body.Close()
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// 幂等键只在同一个接口内有效
+		storeKey := c.Request.Method + " " + c.Request.URL.Path + " " + key
+		fingerprint := idempotency.Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		response, err := config.Store.Begin(c.Request.Context(), storeKey, fingerprint)
+		if errors.Is(err, idempotency.ErrFingerprintMismatch) {
+			c.AbortWithStatusJSON(http.StatusConflict, errorResponse(c, "Idempotency key was already used with a different request"))
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorResponse(c, "Timed out waiting for the original request"))
+			return
+		}
+
+		// 重放已保存的响应
+		if response != nil {
+			for name, values := range response.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(response.StatusCode, response.Header.Get("Content-Type"), response.Body)
+			c.Abort()
+			return
+		}
+
+		// 第一次执行，将幂等键放入请求上下文供处理函数去重，并记录响应以便之后重放
+		c.Request = c.Request.WithContext(idempotency.NewContext(c.Request.Context(), key))
+		w := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		completed := false
+		defer func() {
+			// 处理器panic或返回5xx时放弃该键
+			if !completed {
+				config.Store.Abort(storeKey)
+			}
+		}()
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+		if w.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		config.Store.Complete(storeKey, &idempotency.Response{
+			StatusCode: w.Status(),
+			Header:     http.Header{"Content-Type": w.Header().Values("Content-Type")},
+			Body:       w.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+// teeWriter 在写给客户端的同时保存响应体
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 写入响应体并保存一份副本
+func (w *teeWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString 写入字符串并保存一份副本
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+)
+
+// newIdempotencyRouter 按 main 中的顺序注册中间件，重试中间件最后注册
+func newIdempotencyRouter(retryConfig *RetryConfig) *gin.Engine {
+	router := gin.New()
+	router.Use(
+		RequestIDMiddleware(),
+		TimeoutMiddleware(&TimeoutConfig{Timeout: time.Minute}, nil),
+		IdempotencyMiddleware(&IdempotencyConfig{
+			Header:  "Idempotency-Key",
+			Methods: []string{http.MethodPost},
+			Store:   idempotency.NewMemoryStore(time.Hour, nil),
+		}),
+		RetryMiddleware(retryConfig),
+	)
+	return router
+}
+
+// postOrder 发送携带幂等键的下单请求
+func postOrder(router *gin.Engine, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"customer_id":"c-1"}`))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyKeyAloneDoesNotMakePOSTRetrySafe(t *testing.T) {
+	calls := 0
+	router := newIdempotencyRouter(testRetryConfig())
+	router.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	w := postOrder(router, "k-1")
+	if calls != 1 {
+		t.Fatalf("failing handler ran %d times, want 1", calls)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want the handler's 503", w.Code)
+	}
+}
+
+func TestIdempotentRouteRetriesWithKey(t *testing.T) {
+	config := testRetryConfig()
+	config.Policies = append(config.Policies, RetryPolicy{Method: http.MethodPost, Path: "/orders", Enabled: true, Idempotent: true})
+
+	var keys []string
+	router := newIdempotencyRouter(config)
+	router.POST("/orders", func(c *gin.Context) {
+		keys = append(keys, idempotency.KeyFromContext(c.Request.Context()))
+		if len(keys) < 3 {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		c.String(http.StatusCreated, "created")
+	})
+
+	// 没有幂等键时不重试
+	postOrder(router, "")
+	if len(keys) != 1 || keys[0] != "" {
+		t.Fatalf("handler saw keys %q without an idempotency key, want one call", keys)
+	}
+
+	// 每次重试都能从上下文中取得幂等键用于去重
+	keys = nil
+	w := postOrder(router, "k-1")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d, want 201 after retries", w.Code)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("handler ran %d times, want 3", len(keys))
+	}
+	for _, key := range keys {
+		if key != "k-1" {
+			t.Fatalf("handler saw key %q, want k-1", key)
+		}
+	}
+
+	// 重复请求重放保存的响应，不再执行处理函数
+	w = postOrder(router, "k-1")
+	if len(keys) != 3 || w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("duplicate got %d (replayed=%q) after %d calls, want the replayed 201", w.Code, w.Header().Get(IdempotentReplayedHeader), len(keys))
+	}
+}
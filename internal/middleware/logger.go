@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestLoggerMiddleware 创建请求级日志中间件
+//
+// 为每个请求创建带有方法和路由的日志条目并放入请求上下文，后续通过
+// logger.FromContext 获取，日志中会自动带上请求ID和追踪ID。
+// 应注册在请求ID和追踪中间件之后
+func RequestLoggerMiddleware(log *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+
+		entry := log.WithFields(logrus.Fields{
+			"method": c.Request.Method,
+			"route":  path,
+		})
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), entry))
+
+		c.Next()
+	}
+}
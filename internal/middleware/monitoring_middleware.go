@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+	"github.com/saixiaoxi/high-availability-system/pkg/circuitbreaker"
+	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
 )
 
 // MonitoringMiddleware 创建一个用于记录API指标的中间件
@@ -35,9 +38,9 @@ func MonitoringMiddleware(monitor *monitors.MonitorWithFallback) gin.HandlerFunc
 			"status": status,
 		}
 
-		// 不阻塞请求处理
+		// 不阻塞请求处理；请求结束后 gin.Context 会被复用，goroutine中不能再访问它
 		go func() {
-			ctx := c.Request.Context()
+			ctx := context.Background()
 
 			// 记录请求计数
 			_ = monitor.Counter(ctx, "http_requests_total", 1, labels)
@@ -78,35 +81,46 @@ func ErrorMonitoring(monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
 
 				// 记录错误指标
 				go func() {
-					ctx := c.Request.Context()
-					_ = monitor.Counter(ctx, "http_errors_total", 1, labels)
+					_ = monitor.Counter(context.Background(), "http_errors_total", 1, labels)
 				}()
 			}
 		}
 	}
 }
 
-// HealthCheckHandler 创建健康检查处理函数
-func HealthCheckHandler(monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
+// HealthCheckHandler 创建健康检查处理函数，同时展示各熔断器的状态
+//
+// 任一熔断器处于打开或半开状态时总体状态为DEGRADED，仍返回200以免被摘除流量
+func HealthCheckHandler(monitor *monitors.MonitorWithFallback, breakers ...*circuitbreaker.Breaker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 检查监控系统状态
 		isHealthy, _ := monitor.IsHealthy(c)
 
+		details := gin.H{}
 		if isHealthy {
-			c.JSON(200, gin.H{
-				"status": "UP",
-				"details": gin.H{
-					"monitoring": "UP",
-				},
-			})
+			details["monitoring"] = "UP"
 		} else {
-			c.JSON(200, gin.H{
-				"status": "UP",
-				"details": gin.H{
-					"monitoring": "DOWN",
-					"notes":      "Using fallback strategy for monitoring",
-				},
-			})
+			details["monitoring"] = "DOWN"
+			details["notes"] = "Using fallback strategy for monitoring"
 		}
+
+		// 熔断器状态
+		status := healthcheck.StatusUp
+		if len(breakers) > 0 {
+			states := gin.H{}
+			for _, breaker := range breakers {
+				state := breaker.State()
+				if state != circuitbreaker.StateClosed {
+					status = healthcheck.StatusDegraded
+				}
+				states[breaker.Name()] = state.String()
+			}
+			details["circuit_breakers"] = states
+		}
+
+		c.JSON(200, gin.H{
+			"status":  status,
+			"details": details,
+		})
 	}
 }
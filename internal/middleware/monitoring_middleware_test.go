@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/circuitbreaker"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+func TestHealthCheckHandlerReportsBreakerDegradation(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	config := circuitbreaker.DefaultConfig("payment-service")
+	config.ConsecutiveFailures = 1
+	config.OpenTimeout = 10 * time.Second
+	config.Clock = fake
+	payment := circuitbreaker.New(config)
+	notification := circuitbreaker.New(circuitbreaker.DefaultConfig("notification-service"))
+
+	monitor, _ := newTestMonitor()
+	router := gin.New()
+	router.GET("/health", HealthCheckHandler(monitor, payment, notification))
+
+	check := func(wantStatus, wantPayment string) {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code %d, want 200", w.Code)
+		}
+
+		var body struct {
+			Status  string `json:"status"`
+			Details struct {
+				CircuitBreakers map[string]string `json:"circuit_breakers"`
+			} `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Status != wantStatus {
+			t.Errorf("status %q, want %q", body.Status, wantStatus)
+		}
+		if got := body.Details.CircuitBreakers["payment-service"]; got != wantPayment {
+			t.Errorf("payment breaker %q, want %q", got, wantPayment)
+		}
+		if got := body.Details.CircuitBreakers["notification-service"]; got != "closed" {
+			t.Errorf("notification breaker %q, want closed", got)
+		}
+	}
+
+	check("UP", "closed")
+
+	_ = payment.Execute(context.Background(), func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	check("DEGRADED", "open")
+
+	fake.Advance(config.OpenTimeout)
+	check("DEGRADED", "half_open")
+
+	// 半开状态下连续成功后关闭
+	for i := 0; i < config.HalfOpenMaxProbes; i++ {
+		if err := payment.Execute(context.Background(), func(context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("UP", "closed")
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
+)
+
+// ProbeHandler 创建由一组健康检查支持的探针处理函数，用于 /livez、/readyz 和 /startupz
+//
+// 总体状态按 statusCodes 映射为HTTP状态码，为nil时使用 healthcheck.DefaultStatusCodes，
+// 映射中没有的状态返回503。检查器处于后台模式时返回缓存的结果。默认只返回总体状态，
+// 带有 ?verbose 参数时返回每个检查的详细结果
+func ProbeHandler(checker *healthcheck.Checker, statusCodes map[healthcheck.Status]int) gin.HandlerFunc {
+	if statusCodes == nil {
+		statusCodes = healthcheck.DefaultStatusCodes()
+	}
+
+	return func(c *gin.Context) {
+		result := checker.Results(c.Request.Context())
+
+		statusCode, ok := statusCodes[result.Status]
+		if !ok {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		if !verbose(c) {
+			c.JSON(statusCode, gin.H{"status": result.Status})
+			return
+		}
+		c.JSON(statusCode, result)
+	}
+}
+
+// HealthHistoryHandler 创建返回健康状态切换历史的处理函数
+//
+// readiness 为就绪检查中各检查的切换记录，monitoring 为主监控系统的切换记录，
+// 均按时间从早到晚排列
+func HealthHistoryHandler(readiness *healthcheck.Checker, monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"readiness":  nonNilTransitions(readiness.History()),
+			"monitoring": nonNilTransitions(monitor.History()),
+		})
+	}
+}
+
+// nonNilTransitions 使没有切换记录时返回空数组而不是null
+func nonNilTransitions(history []healthcheck.Transition) []healthcheck.Transition {
+	if history == nil {
+		return []healthcheck.Transition{}
+	}
+	return history
+}
+
+// verbose 判断请求是否带有 verbose 参数，?verbose、?verbose=1 和 ?verbose=true 均视为开启
+func verbose(c *gin.Context) bool {
+	value, ok := c.GetQuery("verbose")
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "", "1", "true":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
+)
+
+// probeRouter 按 main 中的方式组装三个探针
+type probeRouter struct {
+	router   *gin.Engine
+	startup  *healthcheck.ToggleCheck
+	shutdown *healthcheck.ToggleCheck
+	alive    *healthcheck.ToggleCheck
+}
+
+func newProbeRouter(t *testing.T) *probeRouter {
+	p := &probeRouter{
+		router:   gin.New(),
+		startup:  healthcheck.NewToggleCheck("startup", false),
+		shutdown: healthcheck.NewToggleCheck("shutdown", true),
+		alive:    healthcheck.NewToggleCheck("ping", true),
+	}
+
+	liveness := healthcheck.NewChecker()
+	liveness.AddCheck(p.alive)
+
+	startup := healthcheck.NewChecker()
+	startup.AddCheck(p.startup)
+
+	readiness := healthcheck.NewChecker()
+	readiness.AddCheck(p.startup)
+	readiness.AddCheck(p.shutdown)
+	readiness.AddCheckWithCriticality(healthcheck.NewCustomCheck("notification", func(context.Context) (healthcheck.Status, error) {
+		return healthcheck.StatusDown, errors.New("connection refused")
+	}), healthcheck.NonCritical)
+	readiness.Start(time.Hour)
+	t.Cleanup(readiness.Stop)
+
+	p.router.GET("/livez", ProbeHandler(liveness, nil))
+	p.router.GET("/readyz", ProbeHandler(readiness, nil))
+	p.router.GET("/startupz", ProbeHandler(startup, nil))
+	return p
+}
+
+// get 请求探针并返回状态码和响应体
+func (p *probeRouter) get(t *testing.T, path string) (int, map[string]json.RawMessage) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	p.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: invalid body %q", path, w.Body.String())
+	}
+	return w.Code, body
+}
+
+func TestProbesReturn503WhenUnhealthy(t *testing.T) {
+	p := newProbeRouter(t)
+	p.alive.SetDown("deadlock detected")
+
+	for _, path := range []string{"/livez", "/readyz", "/startupz"} {
+		code, body := p.get(t, path)
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", path, code)
+		}
+		if string(body["status"]) != `"DOWN"` {
+			t.Errorf("%s: body status %s, want DOWN", path, body["status"])
+		}
+	}
+}
+
+func TestProbeVerboseDetails(t *testing.T) {
+	p := newProbeRouter(t)
+	p.startup.SetUp()
+
+	tests := []struct {
+		query   string
+		details bool
+	}{
+		{"", false},
+		{"?verbose", true},
+		{"?verbose=1", true},
+		{"?verbose=true", true},
+		{"?verbose=false", false},
+	}
+
+	for _, tt := range tests {
+		code, body := p.get(t, "/readyz"+tt.query)
+		if code != http.StatusOK || string(body["status"]) != `"DEGRADED"` {
+			t.Fatalf("%q: got %d %s, want 200 DEGRADED", tt.query, code, body["status"])
+		}
+
+		_, hasDetails := body["details"]
+		if hasDetails != tt.details {
+			t.Fatalf("%q: details present = %v, want %v", tt.query, hasDetails, tt.details)
+		}
+		if !tt.details {
+			continue
+		}
+
+		var details map[string]healthcheck.Result
+		if err := json.Unmarshal(body["details"], &details); err != nil {
+			t.Fatal(err)
+		}
+		notification := details["notification"]
+		if len(details) != 3 || notification.Status != healthcheck.StatusDown || notification.Critical || notification.Error != "connection refused" {
+			t.Fatalf("%q: got details %+v", tt.query, details)
+		}
+	}
+}
+
+func TestReadinessFlipsOnShutdown(t *testing.T) {
+	p := newProbeRouter(t)
+
+	if code, _ := p.get(t, "/startupz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("startup status %d before startup completes, want 503", code)
+	}
+	p.startup.SetUp()
+	if code, _ := p.get(t, "/startupz"); code != http.StatusOK {
+		t.Fatalf("startup status %d after startup, want 200", code)
+	}
+	if code, _ := p.get(t, "/readyz"); code != http.StatusOK {
+		t.Fatalf("readiness status %d before shutdown, want 200", code)
+	}
+
+	// 开始优雅关闭后立即摘除流量，进程仍然存活
+	p.shutdown.SetDown("server is shutting down")
+	if code, _ := p.get(t, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness status %d during shutdown, want 503", code)
+	}
+	if code, _ := p.get(t, "/livez"); code != http.StatusOK {
+		t.Fatalf("liveness status %d during shutdown, want 200", code)
+	}
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// RateLimitRule 定义一个路由组的限流规则
+type RateLimitRule struct {
+	Prefix string  `mapstructure:"prefix"` // 路由前缀，如 /api/v1/orders
+	Rate   float64 `mapstructure:"rate"`   // 每秒补充的令牌数
+	Burst  int     `mapstructure:"burst"`  // 令牌桶容量
+}
+
+// RateLimitConfig 定义限流中间件的配置
+type RateLimitConfig struct {
+	Rules   []RateLimitRule           // 按路由组配置的规则，按完整路径段匹配最长前缀
+	KeyFunc func(*gin.Context) string // 客户端标识函数，为nil时使用客户端IP
+	Clock   clock.Clock               // 计算令牌补充的时钟，为nil时使用系统时钟
+}
+
+// RateLimitKeyByIP 使用客户端IP作为限流标识
+func RateLimitKeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimitKeyByAPIKey 使用请求头中经过校验的API Key作为限流标识
+//
+// 请求头由客户端任意填写，只有 valid 认可的API Key 才单独限流；没有API Key
+// 或API Key未通过校验时退回客户端IP，避免客户端通过更换请求头绕过限流
+func RateLimitKeyByAPIKey(header string, valid func(key string) bool) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		if key := c.GetHeader(header); key != "" && valid(key) {
+			return "key:" + key
+		}
+		return RateLimitKeyByIP(c)
+	}
+}
+
+// tokenBucket 是单个客户端的令牌桶
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter 为一条规则保存所有客户端的令牌桶
+type rateLimiter struct {
+	rule      RateLimitRule
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// newRateLimiter 创建一条规则的限流器，now 为创建时间
+func newRateLimiter(rule RateLimitRule, now time.Time) *rateLimiter {
+	return &rateLimiter{
+		rule:      rule,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: now,
+	}
+}
+
+// rateLimitResult 是一次限流判断的结果
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int           // 剩余令牌数
+	reset      time.Duration // 令牌桶补满所需的时间
+	retryAfter time.Duration // 下一个令牌可用前的等待时间
+}
+
+// allow 为客户端取一个令牌
+func (l *rateLimiter) allow(key string, now time.Time) rateLimitResult {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sweep(now)
+
+	burst := float64(l.rule.Burst)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	// 按经过的时间补充令牌
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.lastSeen).Seconds()*l.rule.Rate)
+	bucket.lastSeen = now
+
+	result := rateLimitResult{allowed: bucket.tokens >= 1}
+	if result.allowed {
+		bucket.tokens--
+	} else {
+		result.retryAfter = l.refillTime(1 - bucket.tokens)
+	}
+	result.remaining = int(bucket.tokens)
+	result.reset = l.refillTime(burst - bucket.tokens)
+
+	return result
+}
+
+// refillTime 返回补充n个令牌所需的时间
+func (l *rateLimiter) refillTime(n float64) time.Duration {
+	return time.Duration(n / l.rule.Rate * float64(time.Second))
+}
+
+// sweep 定期清理已补满的空闲令牌桶，它们与新建的令牌桶等价，调用方需持有锁
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	refill := l.refillTime(float64(l.rule.Burst))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware 创建按客户端限流的中间件
+//
+// 请求按路由模板匹配最长前缀的规则，前缀按完整路径段匹配（/api/v1/orders 匹配
+// /api/v1/orders/:id，不匹配 /api/v1/orders-archive），未匹配的请求不限流。响应中包含
+// X-RateLimit-Limit/Remaining/Reset 头，超限时返回429；
+// 拒绝次数通过 rate_limit_rejections_total{route} 指标导出
+func RateLimitMiddleware(config *RateLimitConfig, monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = RateLimitKeyByIP
+	}
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	limiters := make([]*rateLimiter, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		if rule.Rate <= 0 || rule.Burst <= 0 {
+			continue
+		}
+		limiters = append(limiters, newRateLimiter(rule, clk.Now()))
+	}
+
+	return func(c *gin.Context) {
+		limiter := matchRateLimiter(limiters, c.FullPath())
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		result := limiter.allow(keyFunc(c), clk.Now())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limiter.rule.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.reset.Seconds()))))
+
+		if !result.allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(c, "Rate limit exceeded"))
+
+			labels := map[string]string{"route": limiter.rule.Prefix}
+			go func() {
+				_ = monitor.Counter(context.Background(), "rate_limit_rejections_total", 1, labels)
+			}()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// matchRateLimiter 返回匹配路由模板最长前缀的限流器
+func matchRateLimiter(limiters []*rateLimiter, path string) *rateLimiter {
+	if path == "" {
+		return nil
+	}
+
+	var matched *rateLimiter
+	for _, limiter := range limiters {
+		if !hasPathPrefix(path, limiter.rule.Prefix) {
+			continue
+		}
+		if matched == nil || len(limiter.rule.Prefix) > len(matched.rule.Prefix) {
+			matched = limiter
+		}
+	}
+	return matched
+}
+
+// hasPathPrefix 判断 path 是否以 prefix 开头，且 prefix 结束在路径段的边界上
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
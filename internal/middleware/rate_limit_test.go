@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+func TestMatchRateLimiterWholeSegments(t *testing.T) {
+	orders := newRateLimiter(RateLimitRule{Prefix: "/api/v1/orders", Rate: 1, Burst: 1}, time.Time{})
+	api := newRateLimiter(RateLimitRule{Prefix: "/api/v1/", Rate: 1, Burst: 1}, time.Time{})
+	limiters := []*rateLimiter{orders, api}
+
+	tests := []struct {
+		path string
+		want *rateLimiter
+	}{
+		{"/api/v1/orders", orders},
+		{"/api/v1/orders/:id", orders},
+		{"/api/v1/ordersXYZ", api},
+		{"/api/v1/products", api},
+		{"/api/v2/orders", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := matchRateLimiter(limiters, tt.path); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitKeyByAPIKeyRequiresValidKey(t *testing.T) {
+	keyFunc := RateLimitKeyByAPIKey("X-API-Key", func(key string) bool {
+		return key == "registered"
+	})
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"registered", "key:registered"},
+		{"made-up", "ip:192.0.2.1"},
+		{"", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if tt.header != "" {
+			c.Request.Header.Set("X-API-Key", tt.header)
+		}
+		if got := keyFunc(c); got != tt.want {
+			t.Errorf("header %q: got %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	l := newRateLimiter(RateLimitRule{Prefix: "/", Rate: 10, Burst: 10}, fake.Now())
+
+	for _, key := range []string{"a", "b", "c"} {
+		l.allow(key, fake.Now())
+	}
+	fake.Advance(59 * time.Second)
+	l.allow("a", fake.Now())
+
+	// 一分钟后清理已补满的空闲令牌桶，刚使用过的保留
+	fake.Advance(time.Second)
+	l.allow("a", fake.Now())
+	if len(l.buckets) != 1 {
+		t.Fatalf("%d buckets after sweep, want 1", len(l.buckets))
+	}
+}
+
+// rateLimitRouter 创建使用假时钟的限流路由
+func rateLimitRouter(fake *clock.Fake, rule RateLimitRule) (*gin.Engine, *recordingMonitor) {
+	monitor, recorder := newTestMonitor()
+	router := gin.New()
+	router.Use(RateLimitMiddleware(&RateLimitConfig{
+		Rules: []RateLimitRule{rule},
+		Clock: fake,
+	}, monitor))
+	router.GET("/api/v1/orders", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router, recorder
+}
+
+func TestRateLimitMiddlewareRefillsWithClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	router, recorder := rateLimitRouter(fake, RateLimitRule{Prefix: "/api/v1/orders", Rate: 0.5, Burst: 2})
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil))
+		return w
+	}
+	assertHeaders := func(w *httptest.ResponseRecorder, status int, remaining, reset string) {
+		t.Helper()
+		if w.Code != status {
+			t.Fatalf("status %d, want %d", w.Code, status)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("X-RateLimit-Limit %q, want 2", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != remaining {
+			t.Errorf("X-RateLimit-Remaining %q, want %s", got, remaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Reset"); got != reset {
+			t.Errorf("X-RateLimit-Reset %q, want %s", got, reset)
+		}
+	}
+
+	// 每个令牌需要2秒补充
+	assertHeaders(get(), http.StatusOK, "1", "2")
+	assertHeaders(get(), http.StatusOK, "0", "4")
+
+	w := get()
+	assertHeaders(w, http.StatusTooManyRequests, "0", "4")
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After %q, want 2", got)
+	}
+	eventually(t, func() bool { return recorder.counter("rate_limit_rejections_total") == 1 })
+
+	// 半个令牌仍然不够，Retry-After 向上取整
+	fake.Advance(time.Second)
+	w = get()
+	assertHeaders(w, http.StatusTooManyRequests, "0", "3")
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After %q, want 1", got)
+	}
+
+	fake.Advance(time.Second)
+	assertHeaders(get(), http.StatusOK, "0", "4")
+
+	// 补满后不再累积令牌
+	fake.Advance(time.Minute)
+	assertHeaders(get(), http.StatusOK, "1", "2")
+}
+
+func TestRateLimitMiddlewareSkipsUnmatchedRoutes(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	router, _ := rateLimitRouter(fake, RateLimitRule{Prefix: "/api/v1/products", Rate: 1, Burst: 1})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil))
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("request %d: status %d, headers %v", i, w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/models"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+)
+
+// RequestIDKey 是在 gin.Context 中保存请求ID的键
+const RequestIDKey = "request_id"
+
+// RequestIDMiddleware 创建请求ID中间件
+//
+// 使用客户端传入的 X-Request-ID，没有或不合法时生成新的ID；
+// 请求ID写入请求上下文和响应头，应作为第一个中间件注册
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.Generate()
+		}
+
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Set(RequestIDKey, id)
+		c.Header(requestid.Header, id)
+
+		c.Next()
+	}
+}
+
+// errorResponse 创建带有请求ID的错误响应
+func errorResponse(c *gin.Context, errMsg string) models.ApiResponse {
+	return models.NewErrorResponse(errMsg).WithRequestID(requestid.FromContext(c.Request.Context()))
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/models"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"valid incoming ID", "client-req-1", true},
+		{"missing", "", false},
+		{"oversized", strings.Repeat("a", 129), false},
+		{"non printable", "req-1\r\nX-Injected: 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			router := gin.New()
+			router.Use(RequestIDMiddleware())
+			router.GET("/", func(c *gin.Context) {
+				seen = requestid.FromContext(c.Request.Context())
+				if c.GetString(RequestIDKey) != seen {
+					t.Errorf("gin context has %q, request context has %q", c.GetString(RequestIDKey), seen)
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestid.Header, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if tt.keep && seen != tt.incoming {
+				t.Fatalf("request ID %q, want the incoming %q", seen, tt.incoming)
+			}
+			if !tt.keep && (seen == tt.incoming || !requestid.Valid(seen)) {
+				t.Fatalf("request ID %q, want a freshly generated ID", seen)
+			}
+			if got := w.Header().Get(requestid.Header); got != seen {
+				t.Fatalf("response header %q, want %q", got, seen)
+			}
+		})
+	}
+}
+
+func TestErrorResponseIncludesRequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/fail", func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, errorResponse(c, "Server is overloaded, please retry later"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(requestid.Header, "client-req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response models.ApiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Success || response.Error == "" || response.RequestID != "client-req-1" {
+		t.Fatalf("got %+v, want an error response with request_id client-req-1", response)
+	}
+}
@@ -5,10 +5,19 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
 	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/sirupsen/logrus"
 )
 
 // RetryConfig 定义重试中间件的配置
@@ -18,8 +27,33 @@ type RetryConfig struct {
 	MaxInterval     time.Duration
 	Multiplier      float64
 	RandomFactor    float64
+	Backoff         retry.Backoff // 为nil时使用带抖动的指数退避
+	Budget          *retry.Budget // 共享的重试预算，为nil时不限制
+	Clock           clock.Clock   // 退避等待和解析 Retry-After 使用的时钟，为nil时使用系统时钟
+	Timeout         time.Duration // 所有尝试的总时间，为0时只受请求上下文的截止时间限制
 	RetryableStatus []int
 	ErrorHandler    func(*gin.Context, error)
+
+	// RetryableMethods 默认允许重试的幂等方法，其他方法只有路由策略设置了
+	// Idempotent 且请求带有幂等键中间件接受的幂等键时才重试
+	RetryableMethods []string
+	// Policies 按路由配置的重试策略，未匹配的路由使用上面的默认配置
+	Policies []RetryPolicy
+}
+
+// RetryPolicy 定义单个路由的重试策略
+type RetryPolicy struct {
+	Method          string        // 请求方法，为空时匹配所有方法
+	Path            string        // 路由模板（如 /api/v1/orders/:id）或请求路径
+	Enabled         bool          // 是否对该路由启用重试
+	MaxAttempts     int           // 最大尝试次数，为0时沿用默认配置
+	RetryableStatus []int         // 可重试的状态码，为空时沿用默认配置
+	Backoff         retry.Backoff // 退避策略，为nil时沿用默认配置
+	Timeout         time.Duration // 所有尝试的总时间，为0时沿用默认配置
+
+	// Idempotent 表示处理函数按幂等键对副作用去重（见 idempotency.KeyFromContext），
+	// 只有这样的路由才会重试携带幂等键的非幂等请求
+	Idempotent bool
 }
 
 // DefaultRetryConfig 返回默认的重试配置
@@ -31,33 +65,186 @@ func DefaultRetryConfig() *RetryConfig {
 		Multiplier:      2.0,
 		RandomFactor:    0.5,
 		RetryableStatus: []int{
+			http.StatusTooManyRequests,
 			http.StatusInternalServerError,
 			http.StatusBadGateway,
 			http.StatusServiceUnavailable,
 			http.StatusGatewayTimeout,
 		},
+		RetryableMethods: []string{http.MethodGet, http.MethodPut, http.MethodDelete},
+		Policies: []RetryPolicy{
+			{Path: "/internal/healthcheck", Enabled: false},
+			{Path: "/metrics", Enabled: false},
+			{Path: "/livez", Enabled: false},
+			{Path: "/readyz", Enabled: false},
+			{Path: "/startupz", Enabled: false},
+		},
 	}
 }
 
-// RetryMiddleware 创建一个Gin重试中间件
-func RetryMiddleware(config *RetryConfig) gin.HandlerFunc {
-	// 如果没有提供配置，使用默认配置
-	if config == nil {
-		config = DefaultRetryConfig()
-	}
+// resolvedPolicy 是预先计算好的路由重试策略
+type resolvedPolicy struct {
+	enabled         bool
+	retryConfig     *retry.Config
+	retryableStatus []int
+	timeout         time.Duration
+	idempotent      bool
+}
 
+// retryPolicies 按方法和路径查找重试策略
+type retryPolicies struct {
+	policies []RetryPolicy
+	resolved []*resolvedPolicy
+	fallback *resolvedPolicy
+	methods  map[string]bool
+}
+
+// newRetryPolicies 根据配置预先计算每个路由的重试策略
+func newRetryPolicies(config *RetryConfig) *retryPolicies {
 	// 转换为重试包的配置
-	retryConfig := &retry.Config{
+	base := &retry.Config{
 		MaxAttempts:         config.MaxAttempts,
 		InitialInterval:     config.InitialInterval,
 		MaxInterval:         config.MaxInterval,
 		Multiplier:          config.Multiplier,
 		RandomizationFactor: config.RandomFactor,
+		Backoff:             config.Backoff,
+		Budget:              config.Budget,
+		Clock:               config.Clock,
+	}
+
+	p := &retryPolicies{
+		policies: config.Policies,
+		fallback: &resolvedPolicy{
+			enabled:         true,
+			retryConfig:     base,
+			retryableStatus: config.RetryableStatus,
+			timeout:         config.Timeout,
+		},
+		methods: make(map[string]bool, len(config.RetryableMethods)),
+	}
+
+	for _, method := range config.RetryableMethods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+
+	for _, policy := range config.Policies {
+		retryConfig := *base
+		if policy.MaxAttempts > 0 {
+			retryConfig.MaxAttempts = policy.MaxAttempts
+		}
+		if policy.Backoff != nil {
+			retryConfig.Backoff = policy.Backoff
+		}
+
+		retryableStatus := config.RetryableStatus
+		if len(policy.RetryableStatus) > 0 {
+			retryableStatus = policy.RetryableStatus
+		}
+
+		timeout := config.Timeout
+		if policy.Timeout > 0 {
+			timeout = policy.Timeout
+		}
+
+		p.resolved = append(p.resolved, &resolvedPolicy{
+			enabled:         policy.Enabled,
+			retryConfig:     &retryConfig,
+			retryableStatus: retryableStatus,
+			timeout:         timeout,
+			idempotent:      policy.Idempotent,
+		})
+	}
+
+	return p
+}
+
+// match 返回请求适用的重试策略，不允许重试时返回nil
+func (p *retryPolicies) match(c *gin.Context) *resolvedPolicy {
+	policy := p.fallback
+	for i, candidate := range p.policies {
+		if candidate.Method != "" && !strings.EqualFold(candidate.Method, c.Request.Method) {
+			continue
+		}
+		if candidate.Path != c.FullPath() && candidate.Path != c.Request.URL.Path {
+			continue
+		}
+		policy = p.resolved[i]
+		break
+	}
+
+	if !policy.enabled {
+		return nil
+	}
+
+	// 非幂等方法重试会重复执行副作用（如重复扣款）。只有请求头中有幂等键还不够：
+	// 幂等键中间件只保存最终响应，每次重试仍会执行处理函数，因此还要求处理函数按幂等键去重
+	if !p.methods[c.Request.Method] && (!policy.idempotent || idempotency.KeyFromContext(c.Request.Context()) == "") {
+		return nil
+	}
+
+	return policy
+}
+
+// RetryAttemptsHeader 是返回给客户端的实际尝试次数响应头
+const RetryAttemptsHeader = "X-Retry-Attempts"
+
+// RetryAttemptsKey 是在 gin.Context 中保存实际尝试次数的键
+const RetryAttemptsKey = "retry_attempts"
+
+// RetryMiddleware 创建一个Gin重试中间件
+//
+// 每次尝试都直接调用路由的处理函数，响应在内存中缓冲，只有最后一次尝试的
+// 响应会写给客户端。由于Gin无法回退处理链，注册在本中间件之后的中间件
+// （包括路由组的中间件）在重试时不会被执行，因此本中间件必须最后一个注册；
+// 路由的处理链中本中间件之后还有其他中间件时，该路由不重试并记录一条警告。
+// 重试的截止时间沿用请求上下文（如超时中间件设置的截止时间），路由策略
+// 配置了 Timeout 时取两者中较早的
+func RetryMiddleware(config *RetryConfig) gin.HandlerFunc {
+	// 如果没有提供配置，使用默认配置
+	if config == nil {
+		config = DefaultRetryConfig()
+	}
+
+	policies := newRetryPolicies(config)
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
 	}
 
-	return func(c *gin.Context) {
-		// 只为外部服务请求创建重试
-		if c.Request.URL.Path == "/internal/healthcheck" || c.Request.URL.Path == "/metrics" {
+	// 按路由缓存处理链中位于本中间件之后的中间件
+	var selfName string
+	var skipped sync.Map
+
+	middleware := func(c *gin.Context) {
+		// 未匹配路由的请求没有可重试的处理函数
+		handler := c.Handler()
+		if c.FullPath() == "" || handler == nil {
+			c.Next()
+			return
+		}
+
+		// 重试只重新执行路由处理函数，之后注册的中间件会被跳过，这样的路由不重试
+		route := c.Request.Method + " " + c.FullPath()
+		after, ok := skipped.Load(route)
+		if !ok {
+			names := handlersAfter(c.HandlerNames(), selfName)
+			if len(names) > 0 {
+				logger.FromContext(c.Request.Context(), nil).WithFields(logrus.Fields{
+					"route":       route,
+					"middlewares": names,
+				}).Warn("Retry disabled for route: middlewares registered after the retry middleware would be skipped on retries")
+			}
+			after, _ = skipped.LoadOrStore(route, len(names) > 0)
+		}
+		if after.(bool) {
+			c.Next()
+			return
+		}
+
+		// 按路由策略和请求方法判断是否重试
+		policy := policies.match(c)
+		if policy == nil {
 			c.Next()
 			return
 		}
@@ -67,45 +254,83 @@ func RetryMiddleware(config *RetryConfig) gin.HandlerFunc {
 		if c.Request.Body != nil {
 			requestBody, _ = io.ReadAll(c.Request.Body)
 			c.Request.Body.Close()
-			// 重置Body以便后面的处理器可以读取
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
 
-		// 创建响应记录器来捕获响应
-		w := &responseRecorder{ResponseWriter: c.Writer, statusCode: http.StatusOK}
-		c.Writer = w
-
 		// 创建重试上下文
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-		defer cancel()
+		ctx := c.Request.Context()
+		if policy.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, policy.timeout)
+			defer cancel()
+		}
+
+		originalWriter := c.Writer
+		originalRequest := c.Request
+		errorCount := len(c.Errors)
+		var w *bufferedWriter
+
+		// 处理函数panic时也要恢复原始的写入器和请求，否则上游 Recovery 写的500会进入缓冲区而丢失
+		restore := sync.OnceFunc(func() {
+			c.Writer = originalWriter
+			c.Request = originalRequest
+		})
+		defer restore()
 
 		// 执行带重试的处理
-		err := retry.DoWithContext(ctx, func(ctx context.Context) error {
-			// 重置响应记录器
-			w.statusCode = http.StatusOK
-			w.body.Reset()
-
-			// 重置请求体 (如果有)
-			if len(requestBody) > 0 {
-				c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-			}
+		report, err := retry.DoWithReport(ctx, func(ctx context.Context) error {
+			// 每次尝试使用新的缓冲区，之前中间件设置的响应头作为初始值
+			w = newBufferedWriter(originalWriter)
+			c.Writer = w
 
-			// 执行请求
-			c.Next()
+			// 重置请求体和本次尝试产生的错误
+			c.Request = originalRequest.WithContext(ctx)
+			c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+			c.Errors = c.Errors[:errorCount]
+
+			// 执行路由处理函数
+			handler(c)
 
 			// 检查是否需要重试
-			if shouldRetry(w.statusCode, config.RetryableStatus) {
-				return &httpError{statusCode: w.statusCode}
+			if shouldRetry(w.Status(), policy.retryableStatus) {
+				return &httpError{
+					statusCode: w.Status(),
+					retryAfter: w.Header().Get("Retry-After"),
+					now:        clk.Now(),
+				}
 			}
 
 			return nil
-		}, retryConfig)
+		}, policy.retryConfig)
+
+		// 恢复原始的写入器和请求，并阻止Gin再次执行处理函数
+		restore()
+		c.Abort()
+		c.Set(RetryAttemptsKey, report.Attempts)
 
 		// 处理最终错误
 		if err != nil && config.ErrorHandler != nil {
 			config.ErrorHandler(c, err)
 		}
+
+		// 错误处理函数没有写响应时，将最后一次尝试的响应写给客户端
+		if !c.Writer.Written() && w != nil {
+			c.Header(RetryAttemptsHeader, strconv.Itoa(report.Attempts))
+			w.flush()
+		}
+	}
+	selfName = runtime.FuncForPC(reflect.ValueOf(middleware).Pointer()).Name()
+
+	return middleware
+}
+
+// handlersAfter 返回处理链中位于 self 之后、路由处理函数之前的处理函数名称
+func handlersAfter(names []string, self string) []string {
+	for i := len(names) - 2; i >= 0; i-- {
+		if names[i] == self {
+			return names[i+1 : len(names)-1]
+		}
 	}
+	return nil
 }
 
 // 检查状态码是否应该重试
@@ -121,33 +346,98 @@ func shouldRetry(statusCode int, retryableStatus []int) bool {
 // HTTP错误包装
 type httpError struct {
 	statusCode int
+	retryAfter string    // 响应中的 Retry-After 头
+	now        time.Time // 收到响应的时间，用于解析HTTP日期格式的 Retry-After
 }
 
 func (e *httpError) Error() string {
 	return http.StatusText(e.statusCode)
 }
 
-// 响应记录器用于捕获响应
-type responseRecorder struct {
+// RetryAfter 实现 retry.RetryAfterHint 接口，使重试间隔遵循 Retry-After 头
+func (e *httpError) RetryAfter() time.Duration {
+	delay, _ := retry.ParseRetryAfter(e.retryAfter, e.now)
+	return delay
+}
+
+// bufferedWriter 在内存中缓冲一次尝试的完整响应，直到 flush 时才写给客户端
+type bufferedWriter struct {
 	gin.ResponseWriter
+	header     http.Header
 	statusCode int
+	written    bool
 	body       bytes.Buffer
 }
 
-// 实现ResponseWriter接口的WriteHeader方法
-func (r *responseRecorder) WriteHeader(statusCode int) {
-	r.statusCode = statusCode
-	r.ResponseWriter.WriteHeader(statusCode)
+// newBufferedWriter 创建缓冲写入器，复制底层写入器中已有的响应头
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		statusCode:     http.StatusOK,
+	}
 }
 
-// 实现ResponseWriter接口的Write方法
-func (r *responseRecorder) Write(b []byte) (int, error) {
-	r.body.Write(b)
-	return r.ResponseWriter.Write(b)
+// Header 返回本次尝试的响应头
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
 }
 
-// 实现ResponseWriter接口的WriteString方法
-func (r *responseRecorder) WriteString(s string) (int, error) {
-	r.body.WriteString(s)
-	return r.ResponseWriter.WriteString(s)
+// WriteHeader 记录状态码，不写给客户端
+func (w *bufferedWriter) WriteHeader(statusCode int) {
+	if statusCode > 0 && !w.written {
+		w.statusCode = statusCode
+	}
+}
+
+// WriteHeaderNow 标记响应头已写入
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+// Write 将响应体写入缓冲区
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+// WriteString 将字符串写入缓冲区
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+// Status 返回缓冲的状态码
+func (w *bufferedWriter) Status() int {
+	return w.statusCode
+}
+
+// Size 返回缓冲的响应体大小，未写入时为-1，与Gin保持一致
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Written 返回本次尝试是否写入了响应
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush 缓冲期间不向客户端刷新数据
+func (w *bufferedWriter) Flush() {}
+
+// flush 将缓冲的响应写给底层写入器
+func (w *bufferedWriter) flush() {
+	header := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
 }
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testRetryConfig 返回不等待的重试配置
+func testRetryConfig() *RetryConfig {
+	config := DefaultRetryConfig()
+	config.Backoff = retry.ConstantBackoff{}
+	return config
+}
+
+func TestRetryMiddlewareRecoversPanic(t *testing.T) {
+	router := gin.New()
+	router.Use(gin.RecoveryWithWriter(io.Discard), RetryMiddleware(testRetryConfig()))
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500 written by Recovery", w.Code)
+	}
+}
+
+func TestRetryMiddlewareRetriesUntilSuccess(t *testing.T) {
+	calls := 0
+	router := gin.New()
+	router.Use(RetryMiddleware(testRetryConfig()))
+	router.GET("/flaky", func(c *gin.Context) {
+		calls++
+		if calls < 3 {
+			c.String(http.StatusServiceUnavailable, "unavailable")
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flaky", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("got %d %q, want 200 ok", w.Code, w.Body.String())
+	}
+	if w.Header().Get(RetryAttemptsHeader) != "3" {
+		t.Errorf("attempts header %q, want 3", w.Header().Get(RetryAttemptsHeader))
+	}
+}
+
+func TestRetryMiddlewareSkipsNonIdempotentMethods(t *testing.T) {
+	calls := 0
+	router := gin.New()
+	router.Use(RetryMiddleware(testRetryConfig()))
+	router.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if calls != 1 {
+		t.Fatalf("%d calls without idempotency key, want 1", calls)
+	}
+
+	// 只有请求头中的幂等键不足以重试，幂等键中间件没有接受该键
+	calls = 0
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("Idempotency-Key", "k-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if calls != 1 {
+		t.Fatalf("%d calls with only the idempotency header, want 1", calls)
+	}
+}
+
+func TestRetryMiddlewareUsesRequestDeadline(t *testing.T) {
+	var remaining time.Duration
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}, RetryMiddleware(testRetryConfig()))
+	router.GET("/deadline", func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		if !ok {
+			t.Error("request has no deadline")
+			return
+		}
+		remaining = time.Until(deadline)
+		c.Status(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/deadline", nil))
+	if remaining <= 0 || remaining > time.Second {
+		t.Fatalf("remaining %v, want the 1s request deadline", remaining)
+	}
+}
+
+func TestRetryMiddlewarePolicyTimeout(t *testing.T) {
+	config := testRetryConfig()
+	config.Policies = append(config.Policies, RetryPolicy{Path: "/slow", Enabled: true, Timeout: 50 * time.Millisecond})
+
+	var remaining time.Duration
+	router := gin.New()
+	router.Use(RetryMiddleware(config))
+	router.GET("/slow", func(c *gin.Context) {
+		deadline, _ := c.Request.Context().Deadline()
+		remaining = time.Until(deadline)
+		c.Status(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if remaining <= 0 || remaining > 50*time.Millisecond {
+		t.Fatalf("remaining %v, want the 50ms policy timeout", remaining)
+	}
+}
+
+func TestRetryMiddlewareSkipsRoutesWithLaterMiddleware(t *testing.T) {
+	var output bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&output)
+
+	var groupCalls, calls int
+	router := gin.New()
+	router.Use(RequestLoggerMiddleware(log), RetryMiddleware(testRetryConfig()))
+	router.GET("/plain", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusServiceUnavailable)
+	})
+	group := router.Group("/grouped", func(c *gin.Context) {
+		groupCalls++
+	})
+	group.GET("", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	// 路由组的中间件在重试时会被跳过，因此该路由只执行一次
+	for i := 0; i < 2; i++ {
+		calls, groupCalls = 0, 0
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/grouped", nil))
+		if w.Code != http.StatusServiceUnavailable || calls != 1 || groupCalls != 1 {
+			t.Fatalf("request %d: status %d, %d handler calls, %d group middleware calls, want 503 once", i, w.Code, calls, groupCalls)
+		}
+		if w.Header().Get(RetryAttemptsHeader) != "" {
+			t.Fatalf("request %d: unexpected %s header", i, RetryAttemptsHeader)
+		}
+	}
+	if got := strings.Count(output.String(), "Retry disabled for route"); got != 1 {
+		t.Fatalf("logged the warning %d times, want once: %q", got, output.String())
+	}
+	if !strings.Contains(output.String(), "GET /grouped") {
+		t.Fatalf("warning %q does not name the route", output.String())
+	}
+
+	calls = 0
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plain", nil))
+	if calls != 3 || w.Header().Get(RetryAttemptsHeader) != "3" {
+		t.Fatalf("got %d calls, %s attempts header, want 3 retries on a route without later middleware", calls, w.Header().Get(RetryAttemptsHeader))
+	}
+}
@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+)
+
+// ErrRequestTimeout 表示请求超过了截止时间
+var ErrRequestTimeout = errors.New("request timed out")
+
+// TimeoutRoute 定义单个路由的超时时间
+type TimeoutRoute struct {
+	Method  string        `mapstructure:"method"`  // 请求方法，为空时匹配所有方法
+	Path    string        `mapstructure:"path"`    // 路由模板（如 /api/v1/orders/:id）或请求路径
+	Timeout time.Duration `mapstructure:"timeout"` // 超时时间，为0时该路由不限制
+}
+
+// TimeoutConfig 定义超时中间件的配置
+type TimeoutConfig struct {
+	Timeout time.Duration  // 默认超时时间，为0时不限制
+	Routes  []TimeoutRoute // 按路由覆盖，先匹配的优先
+}
+
+// match 返回请求适用的超时时间
+func (c *TimeoutConfig) match(ctx *gin.Context) time.Duration {
+	for _, route := range c.Routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, ctx.Request.Method) {
+			continue
+		}
+		if route.Path != ctx.FullPath() && route.Path != ctx.Request.URL.Path {
+			continue
+		}
+		return route.Timeout
+	}
+	return c.Timeout
+}
+
+// TimeoutMiddleware 创建请求超时中间件
+//
+// 为请求上下文设置截止时间，处理函数的响应先缓冲在内存中。截止时间到达时
+// 立即向客户端返回504，之后处理函数的写入全部丢弃；处理函数在截止时间前
+// 完成时才将缓冲的响应写给客户端。处理函数应遵循上下文取消尽快返回
+func TimeoutMiddleware(config *TimeoutConfig, monitor *monitors.MonitorWithFallback) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := config.match(c)
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		// 超时响应在监视goroutine中写出，提前生成以避免并发访问 gin.Context
+		body, _ := json.Marshal(errorResponse(c, "Request timed out"))
+
+		originalWriter := c.Writer
+		originalRequest := c.Request
+		w := newTimeoutWriter(ctx, originalWriter)
+		c.Writer = w
+		c.Request = c.Request.WithContext(ctx)
+
+		done := make(chan struct{})
+		watcherDone := make(chan struct{})
+		go func() {
+			defer close(watcherDone)
+			select {
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					w.timeout(body)
+				}
+			case <-done:
+			}
+		}()
+
+		// 处理函数返回（包括panic）后等待监视goroutine退出，保证之后不再写响应
+		stopWatcher := sync.OnceFunc(func() {
+			close(done)
+			<-watcherDone
+			c.Writer = originalWriter
+			c.Request = originalRequest
+		})
+		defer stopWatcher()
+
+		c.Next()
+		finishedLate := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		stopWatcher()
+
+		// 处理函数在截止时间之后返回时，监视goroutine可能先收到 done 而没有写504
+		if finishedLate && !w.timedOut() {
+			w.timeout(body)
+		}
+
+		if w.timedOut() {
+			c.Abort()
+			_ = c.Error(ErrRequestTimeout)
+
+			labels := map[string]string{"path": c.FullPath()}
+			go func() {
+				_ = monitor.Counter(context.Background(), "http_request_timeouts_total", 1, labels)
+			}()
+			return
+		}
+
+		w.flush()
+	}
+}
+
+// timeoutWriter 缓冲处理函数的响应，超时后丢弃所有写入
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx        context.Context // 请求上下文，截止时间到达后的写入也被丢弃
+	header     http.Header
+	statusCode int
+	written    bool
+	body       bytes.Buffer
+	expired    bool
+	mutex      sync.Mutex
+}
+
+// newTimeoutWriter 创建超时写入器，复制底层写入器中已有的响应头
+func newTimeoutWriter(ctx context.Context, w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		ResponseWriter: w,
+		ctx:            ctx,
+		header:         w.Header().Clone(),
+		statusCode:     http.StatusOK,
+	}
+}
+
+// Header 返回处理函数的响应头
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader 记录状态码
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if statusCode > 0 && !w.written && !w.expiredLocked() {
+		w.statusCode = statusCode
+	}
+}
+
+// WriteHeaderNow 标记响应头已写入
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.written = true
+}
+
+// Write 将响应体写入缓冲区，超时后返回 http.ErrHandlerTimeout
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.expiredLocked() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.Write(b)
+}
+
+// WriteString 将字符串写入缓冲区，超时后返回 http.ErrHandlerTimeout
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status 返回缓冲的状态码
+func (w *timeoutWriter) Status() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.statusCode
+}
+
+// Size 返回缓冲的响应体大小，未写入时为-1，与Gin保持一致
+func (w *timeoutWriter) Size() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+// Written 返回处理函数是否写入了响应
+func (w *timeoutWriter) Written() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.written
+}
+
+// Flush 缓冲期间不向客户端刷新数据
+func (w *timeoutWriter) Flush() {}
+
+// timeout 标记超时并向客户端写出504响应
+func (w *timeoutWriter) timeout(body []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.expired = true
+
+	header := w.ResponseWriter.Header()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.WriteHeaderNow()
+	_, _ = w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+// expiredLocked 返回是否已经超时，监视goroutine还没写504时也以上下文为准，调用方需持有锁
+func (w *timeoutWriter) expiredLocked() bool {
+	return w.expired || errors.Is(w.ctx.Err(), context.DeadlineExceeded)
+}
+
+// timedOut 返回是否已经超时
+func (w *timeoutWriter) timedOut() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.expired
+}
+
+// flush 将缓冲的响应写给底层写入器
+func (w *timeoutWriter) flush() {
+	header := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
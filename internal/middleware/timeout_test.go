@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/internal/models"
+	"github.com/saixiaoxi/high-availability-system/internal/monitors"
+	"github.com/sirupsen/logrus"
+)
+
+// recordingMonitor 记录写入的计数器
+type recordingMonitor struct {
+	counters map[string]float64
+	mutex    sync.Mutex
+}
+
+func (m *recordingMonitor) Counter(_ context.Context, name string, value float64, _ map[string]string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counters[name] += value
+	return nil
+}
+
+func (m *recordingMonitor) Gauge(context.Context, string, float64, map[string]string) error {
+	return nil
+}
+
+func (m *recordingMonitor) Histogram(context.Context, string, float64, map[string]string) error {
+	return nil
+}
+
+func (m *recordingMonitor) IsHealthy(context.Context) (bool, error) {
+	return true, nil
+}
+
+// counter 返回计数器的当前值
+func (m *recordingMonitor) counter(name string) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counters[name]
+}
+
+// newTestMonitor 创建写入 recordingMonitor 的监控系统
+func newTestMonitor() (*monitors.MonitorWithFallback, *recordingMonitor) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	primary := &recordingMonitor{counters: make(map[string]float64)}
+	return monitors.NewMonitorWithFallback(primary, nil, 0, monitors.WithMonitorLogger(l)), primary
+}
+
+// eventually 等待条件成立，超过1秒时失败
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTimeoutMiddlewareReturns504(t *testing.T) {
+	monitor, recorder := newTestMonitor()
+	router := gin.New()
+	router.Use(RequestIDMiddleware(), TimeoutMiddleware(&TimeoutConfig{Timeout: 20 * time.Millisecond}, monitor))
+	router.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504", w.Code)
+	}
+	var response models.ApiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid body %q: %v", w.Body.String(), err)
+	}
+	if response.Error != "Request timed out" || response.RequestID == "" {
+		t.Fatalf("got %+v, want the timeout error with a request ID", response)
+	}
+	eventually(t, func() bool { return recorder.counter("http_request_timeouts_total") == 1 })
+}
+
+func TestTimeoutMiddlewareDiscardsLateWrites(t *testing.T) {
+	monitor, _ := newTestMonitor()
+	router := gin.New()
+	router.Use(TimeoutMiddleware(&TimeoutConfig{Timeout: 20 * time.Millisecond}, monitor))
+
+	lateErr := make(chan error, 1)
+	router.GET("/late", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		// 超时后继续写入，与监视goroutine写504并发
+		c.Header("X-Late", "true")
+		c.Status(http.StatusOK)
+		_, err := c.Writer.Write([]byte("late body"))
+		lateErr <- err
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/late", nil))
+
+	if err := <-lateErr; err != http.ErrHandlerTimeout {
+		t.Fatalf("late write returned %v, want http.ErrHandlerTimeout", err)
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504", w.Code)
+	}
+	if w.Header().Get("X-Late") != "" {
+		t.Error("late header reached the client")
+	}
+	var response models.ApiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("body %q mixes in the late write: %v", w.Body.String(), err)
+	}
+}
+
+func TestTimeoutMiddlewareRouteOverrides(t *testing.T) {
+	config := &TimeoutConfig{
+		Timeout: time.Second,
+		Routes: []TimeoutRoute{
+			{Path: "/stream", Timeout: 0},
+			{Method: http.MethodPost, Path: "/items/:id", Timeout: time.Minute},
+		},
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   time.Duration // 0表示没有截止时间
+	}{
+		{http.MethodGet, "/items/1", time.Second},
+		{http.MethodPost, "/items/1", time.Minute},
+		{http.MethodGet, "/stream", 0},
+	}
+
+	for _, tt := range tests {
+		var remaining time.Duration
+		router := gin.New()
+		router.Use(TimeoutMiddleware(config, nil))
+		handler := func(c *gin.Context) {
+			if deadline, ok := c.Request.Context().Deadline(); ok {
+				remaining = time.Until(deadline)
+			}
+			c.Status(http.StatusNoContent)
+		}
+		router.GET("/items/:id", handler)
+		router.POST("/items/:id", handler)
+		router.GET("/stream", handler)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s %s: status %d, want 204", tt.method, tt.path, w.Code)
+		}
+		if tt.want == 0 && remaining != 0 {
+			t.Errorf("%s %s has a deadline, want none", tt.method, tt.path)
+		}
+		if tt.want > 0 && (remaining <= tt.want/2 || remaining > tt.want) {
+			t.Errorf("%s %s: remaining %v, want about %v", tt.method, tt.path, remaining, tt.want)
+		}
+	}
+}
+
+func TestTimeoutMiddlewareWatcherExits(t *testing.T) {
+	router := gin.New()
+	router.Use(TimeoutMiddleware(&TimeoutConfig{Timeout: time.Minute}, nil))
+	router.GET("/fast", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Fatalf("got %d %q, want 200 ok", w.Code, w.Body.String())
+		}
+	}
+
+	// 处理函数正常返回时中间件等待监视goroutine退出，不会遗留goroutine
+	if after := runtime.NumGoroutine(); after > before+5 {
+		t.Fatalf("%d goroutines after 100 requests, started with %d", after, before)
+	}
+}
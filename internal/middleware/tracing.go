@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+)
+
+// TracingMiddleware 创建追踪中间件
+//
+// 从 traceparent 请求头中提取上游的追踪上下文，为每个请求创建服务端跨度，
+// 并在响应头中返回本次请求的 traceparent。应注册在请求ID中间件之后
+func TracingMiddleware(excludedPaths ...string) gin.HandlerFunc {
+	excluded := make(map[string]bool, len(excludedPaths))
+	for _, path := range excludedPaths {
+		excluded[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if excluded[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		// 未匹配路由时使用固定名称，避免跨度名称基数过大
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method + " unmatched"
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, name,
+			tracing.WithSpanKind(tracing.SpanKindServer),
+			tracing.WithAttributes(
+				tracing.Attr("http.method", c.Request.Method),
+				tracing.Attr("http.route", route),
+				tracing.Attr("http.target", c.Request.URL.Path),
+				tracing.Attr("http.client_ip", c.ClientIP()),
+				tracing.Attr("request.id", requestid.FromContext(ctx)),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		if sc := span.SpanContext(); sc.IsValid() {
+			c.Header(tracing.TraceparentHeader, tracing.FormatTraceparent(sc))
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Attr("http.status_code", status))
+		if attempts, ok := c.Get(RetryAttemptsKey); ok {
+			span.SetAttributes(tracing.Attr("http.retry_attempts", attempts))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, strconv.Itoa(status)+" "+http.StatusText(status))
+		}
+	}
+}
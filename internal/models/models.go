@@ -54,10 +54,11 @@ const (
 
 // ApiResponse 通用API响应结构
 type ApiResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 }
 
 // NewSuccessResponse 创建成功响应
@@ -76,3 +77,9 @@ func NewErrorResponse(errMsg string) ApiResponse {
 		Error:   errMsg,
 	}
 }
+
+// WithRequestID 返回附加了请求ID的响应
+func (r ApiResponse) WithRequestID(requestID string) ApiResponse {
+	r.RequestID = requestID
+	return r
+}
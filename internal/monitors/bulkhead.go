@@ -0,0 +1,88 @@
+package monitors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/bulkhead"
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// BulkheadReporter 定期将隔离舱的状态导出为监控指标
+//
+// 导出 bulkhead_in_flight{name}、bulkhead_queue_depth{name} 仪表
+// 和 bulkhead_rejections_total{name,reason} 计数器
+type BulkheadReporter struct {
+	monitor   Monitor
+	bulkheads []*bulkhead.Bulkhead
+	interval  time.Duration
+	clock     clock.Clock
+	last      map[string]map[bulkhead.Reason]uint64
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewBulkheadReporter 创建一个新的隔离舱指标导出器
+//
+// 隔离舱以可变参数传入，需要配置时钟等选项时使用 NewBulkheadReporterWithOptions
+func NewBulkheadReporter(monitor Monitor, interval time.Duration, bulkheads ...*bulkhead.Bulkhead) *BulkheadReporter {
+	return NewBulkheadReporterWithOptions(monitor, interval, bulkheads)
+}
+
+// NewBulkheadReporterWithOptions 创建一个新的隔离舱指标导出器
+func NewBulkheadReporterWithOptions(monitor Monitor, interval time.Duration, bulkheads []*bulkhead.Bulkhead, opts ...ReporterOption) *BulkheadReporter {
+	o := newReporterOptions(opts)
+	return &BulkheadReporter{
+		monitor:   monitor,
+		bulkheads: bulkheads,
+		interval:  interval,
+		clock:     o.clock,
+		last:      make(map[string]map[bulkhead.Reason]uint64),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start 开始定期导出
+func (r *BulkheadReporter) Start() {
+	go func() {
+		ticker := r.clock.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				r.Report(context.Background())
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Report 导出一次当前状态，计数器只上报自上次导出以来的增量
+func (r *BulkheadReporter) Report(ctx context.Context) {
+	for _, b := range r.bulkheads {
+		stats := b.Stats()
+		labels := map[string]string{"name": b.Name()}
+
+		_ = r.monitor.Gauge(ctx, "bulkhead_in_flight", float64(stats.InFlight), labels)
+		_ = r.monitor.Gauge(ctx, "bulkhead_queue_depth", float64(stats.Queued), labels)
+
+		last := r.last[b.Name()]
+		for reason, count := range stats.Rejections {
+			_ = r.monitor.Counter(ctx, "bulkhead_rejections_total", float64(count-last[reason]), map[string]string{
+				"name":   b.Name(),
+				"reason": string(reason),
+			})
+		}
+		r.last[b.Name()] = stats.Rejections
+	}
+}
+
+// Stop 停止定期导出
+func (r *BulkheadReporter) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
@@ -0,0 +1,44 @@
+package monitors
+
+import (
+	"context"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/circuitbreaker"
+)
+
+// CircuitBreakerMetrics 将熔断器状态变化导出为监控指标
+//
+// 导出 circuit_breaker_state{name} 仪表（0关闭，1半开，2打开）
+// 和 circuit_breaker_transitions_total{name,from,to} 计数器
+type CircuitBreakerMetrics struct {
+	monitor Monitor
+}
+
+// NewCircuitBreakerMetrics 创建熔断器指标适配器
+func NewCircuitBreakerMetrics(monitor Monitor) *CircuitBreakerMetrics {
+	return &CircuitBreakerMetrics{monitor: monitor}
+}
+
+// Init 导出熔断器的初始状态
+func (m *CircuitBreakerMetrics) Init(name string) {
+	_ = m.monitor.Gauge(context.Background(), "circuit_breaker_state", float64(circuitbreaker.StateClosed), map[string]string{
+		"name": name,
+	})
+}
+
+// OnStateChange 可直接用作 circuitbreaker.Config.OnStateChange
+func (m *CircuitBreakerMetrics) OnStateChange(name string, from, to circuitbreaker.State) {
+	// 回调在熔断器锁内执行，不阻塞调用方
+	go func() {
+		ctx := context.Background()
+
+		_ = m.monitor.Gauge(ctx, "circuit_breaker_state", float64(to), map[string]string{
+			"name": name,
+		})
+		_ = m.monitor.Counter(ctx, "circuit_breaker_transitions_total", 1, map[string]string{
+			"name": name,
+			"from": from.String(),
+			"to":   to.String(),
+		})
+	}()
+}
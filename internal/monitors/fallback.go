@@ -3,11 +3,12 @@ package monitors
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
 	"github.com/sirupsen/logrus"
 )
 
@@ -123,20 +124,38 @@ func (l *LocalLoggingFallback) Stop() {
 type PeriodicFlusher struct {
 	fallback  *LocalLoggingFallback
 	interval  time.Duration
-	ticker    *time.Ticker
+	ticker    clock.Ticker
+	clock     clock.Clock
 	stopChan  chan struct{}
 	stopOnce  sync.Once
 	isRunning bool
 }
 
+// FlusherOption 用于配置 PeriodicFlusher 的可选项
+type FlusherOption func(*PeriodicFlusher)
+
+// WithFlusherClock 设置定期刷新使用的时钟
+func WithFlusherClock(c clock.Clock) FlusherOption {
+	return func(p *PeriodicFlusher) {
+		p.clock = c
+	}
+}
+
 // NewPeriodicFlusher 创建一个新的定期刷新器
-func NewPeriodicFlusher(fallback *LocalLoggingFallback, interval time.Duration) *PeriodicFlusher {
-	return &PeriodicFlusher{
+func NewPeriodicFlusher(fallback *LocalLoggingFallback, interval time.Duration, opts ...FlusherOption) *PeriodicFlusher {
+	p := &PeriodicFlusher{
 		fallback:  fallback,
 		interval:  interval,
+		clock:     clock.Real(),
 		stopChan:  make(chan struct{}),
 		isRunning: false,
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 // Start 开始定期刷新
@@ -145,13 +164,13 @@ func (p *PeriodicFlusher) Start() {
 		return
 	}
 
-	p.ticker = time.NewTicker(p.interval)
+	p.ticker = p.clock.NewTicker(p.interval)
 	p.isRunning = true
 
 	go func() {
 		for {
 			select {
-			case <-p.ticker.C:
+			case <-p.ticker.C():
 				p.fallback.Flush()
 			case <-p.stopChan:
 				return
@@ -184,5 +203,5 @@ func FlushOnShutdown(fallback *LocalLoggingFallback) {
 
 	// 记录刷新持续时间
 	duration := time.Since(startTime)
-	fmt.Printf("Metrics flushed in %v\n", duration)
+	logger.Default().WithField("duration", duration.String()).Info("Metrics flushed")
 }
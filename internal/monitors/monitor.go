@@ -5,6 +5,11 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/sirupsen/logrus"
 )
 
 var (
@@ -56,24 +61,87 @@ type FallbackStrategy interface {
 type MonitorWithFallback struct {
 	primaryMonitor    Monitor
 	fallbackStrategy  FallbackStrategy
-	healthCheckTicker *time.Ticker
+	healthCheckTicker clock.Ticker
 	isHealthy         bool
+	damping           healthcheck.DampingConfig
+	damper            *healthcheck.Damper
 	mutex             sync.RWMutex
 	periodicCheck     time.Duration
+	clock             clock.Clock
+	stopChan          chan struct{}
+	stopOnce          sync.Once
+	logger            *logrus.Logger
+}
+
+// MonitorOption 用于配置 MonitorWithFallback 的可选项
+type MonitorOption func(*MonitorWithFallback)
+
+// WithMonitorClock 设置周期性健康检查使用的时钟
+func WithMonitorClock(c clock.Clock) MonitorOption {
+	return func(m *MonitorWithFallback) {
+		m.clock = c
+	}
+}
+
+// WithMonitorLogger 设置记录监控系统状态变化的日志器
+func WithMonitorLogger(l *logrus.Logger) MonitorOption {
+	return func(m *MonitorWithFallback) {
+		m.logger = l
+	}
+}
+
+// WithMonitorDamping 设置主监控系统健康状态的抖动抑制，默认每次结果立即生效
+func WithMonitorDamping(config healthcheck.DampingConfig) MonitorOption {
+	return func(m *MonitorWithFallback) {
+		m.damping = config
+	}
+}
+
+// ReporterOption 用于配置定期导出指标的 Reporter 的可选项
+type ReporterOption func(*reporterOptions)
+
+// reporterOptions 是各 Reporter 共用的可选配置
+type reporterOptions struct {
+	clock clock.Clock
+}
+
+// WithReporterClock 设置定期导出使用的时钟
+func WithReporterClock(c clock.Clock) ReporterOption {
+	return func(o *reporterOptions) {
+		o.clock = c
+	}
+}
+
+// newReporterOptions 应用可选项，未设置的字段使用默认值
+func newReporterOptions(opts []ReporterOption) reporterOptions {
+	o := reporterOptions{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
 // NewMonitorWithFallback 创建带有容错的监控系统
-func NewMonitorWithFallback(primaryMonitor Monitor, fallbackStrategy FallbackStrategy, periodicCheck time.Duration) *MonitorWithFallback {
+func NewMonitorWithFallback(primaryMonitor Monitor, fallbackStrategy FallbackStrategy, periodicCheck time.Duration, opts ...MonitorOption) *MonitorWithFallback {
 	m := &MonitorWithFallback{
 		primaryMonitor:   primaryMonitor,
 		fallbackStrategy: fallbackStrategy,
 		isHealthy:        true,
 		periodicCheck:    periodicCheck,
+		clock:            clock.Real(),
+		damping:          healthcheck.DampingConfig{HistorySize: healthcheck.DefaultDampingConfig().HistorySize},
+		stopChan:         make(chan struct{}),
+		logger:           logger.Default(),
 	}
 
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.damper = healthcheck.NewDamper("monitoring", healthcheck.StatusUp, m.damping, m.clock)
+
 	// 定期检查主监控系统的健康状态
 	if periodicCheck > 0 {
-		m.healthCheckTicker = time.NewTicker(periodicCheck)
+		m.healthCheckTicker = m.clock.NewTicker(periodicCheck)
 		go m.periodicHealthCheck()
 	}
 
@@ -82,14 +150,52 @@ func NewMonitorWithFallback(primaryMonitor Monitor, fallbackStrategy FallbackStr
 
 // 定期执行健康检查
 func (m *MonitorWithFallback) periodicHealthCheck() {
-	for range m.healthCheckTicker.C {
+	for {
+		select {
+		case <-m.healthCheckTicker.C():
+		case <-m.stopChan:
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		healthy, _ := m.primaryMonitor.IsHealthy(ctx)
+		healthy, err := m.primaryMonitor.IsHealthy(ctx)
 		cancel()
 
-		m.mutex.Lock()
-		m.isHealthy = healthy
-		m.mutex.Unlock()
+		m.setHealthy(healthy, err)
+	}
+}
+
+// setHealthy 记录一次主监控系统的检查结果，经过抖动抑制后更新健康状态，状态变化时记录日志
+func (m *MonitorWithFallback) setHealthy(healthy bool, err error) {
+	status, reason := healthcheck.StatusUp, ""
+	if !healthy {
+		status = healthcheck.StatusDown
+	}
+	if err != nil {
+		reason = err.Error()
+	}
+
+	m.mutex.Lock()
+	status, changed := m.damper.Observe(status, reason)
+	m.isHealthy = status == healthcheck.StatusUp
+	m.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if status == healthcheck.StatusUp {
+		m.logger.Info("Primary monitoring system recovered")
+	} else {
+		m.logger.Warn("Primary monitoring system unavailable, using fallback strategy")
+	}
+}
+
+// recordSuccess 记录一次成功写入，使之前零星的写入失败不再计入连续失败次数
+//
+// 只有存在未达到阈值的候选状态时才需要交给抖动抑制器，正常情况下只有一次加锁读取
+func (m *MonitorWithFallback) recordSuccess() {
+	if m.damper.Pending() {
+		m.setHealthy(true, nil)
 	}
 }
 
@@ -103,13 +209,12 @@ func (m *MonitorWithFallback) Counter(ctx context.Context, name string, value fl
 	if isHealthy {
 		err := m.primaryMonitor.Counter(ctx, name, value, labels)
 		if err == nil {
+			m.recordSuccess()
 			return nil
 		}
 
 		// 更新健康状态
-		m.mutex.Lock()
-		m.isHealthy = false
-		m.mutex.Unlock()
+		m.setHealthy(false, err)
 	}
 
 	// 如果启用了容错策略，使用容错措施
@@ -130,13 +235,12 @@ func (m *MonitorWithFallback) Gauge(ctx context.Context, name string, value floa
 	if isHealthy {
 		err := m.primaryMonitor.Gauge(ctx, name, value, labels)
 		if err == nil {
+			m.recordSuccess()
 			return nil
 		}
 
 		// 更新健康状态
-		m.mutex.Lock()
-		m.isHealthy = false
-		m.mutex.Unlock()
+		m.setHealthy(false, err)
 	}
 
 	// 如果启用了容错策略，使用容错措施
@@ -157,13 +261,12 @@ func (m *MonitorWithFallback) Histogram(ctx context.Context, name string, value
 	if isHealthy {
 		err := m.primaryMonitor.Histogram(ctx, name, value, labels)
 		if err == nil {
+			m.recordSuccess()
 			return nil
 		}
 
 		// 更新健康状态
-		m.mutex.Lock()
-		m.isHealthy = false
-		m.mutex.Unlock()
+		m.setHealthy(false, err)
 	}
 
 	// 如果启用了容错策略，使用容错措施
@@ -181,9 +284,17 @@ func (m *MonitorWithFallback) IsHealthy(ctx context.Context) (bool, error) {
 	return m.isHealthy, nil
 }
 
+// History 返回主监控系统健康状态的切换记录，按时间从早到晚排列
+func (m *MonitorWithFallback) History() []healthcheck.Transition {
+	return m.damper.History()
+}
+
 // Stop 停止定期健康检查
 func (m *MonitorWithFallback) Stop() {
-	if m.healthCheckTicker != nil {
-		m.healthCheckTicker.Stop()
-	}
+	m.stopOnce.Do(func() {
+		if m.healthCheckTicker != nil {
+			m.healthCheckTicker.Stop()
+		}
+		close(m.stopChan)
+	})
 }
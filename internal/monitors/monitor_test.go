@@ -0,0 +1,209 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/healthcheck"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/sirupsen/logrus"
+)
+
+// stubMonitor 是可控制写入结果和健康状态的监控系统
+type stubMonitor struct {
+	mutex   sync.Mutex
+	healthy bool
+	failing bool
+	checks  chan struct{}
+	metrics []string
+}
+
+func newStubMonitor() *stubMonitor {
+	return &stubMonitor{healthy: true, checks: make(chan struct{}, 16)}
+}
+
+func (s *stubMonitor) set(healthy, failing bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.healthy = healthy
+	s.failing = failing
+}
+
+func (s *stubMonitor) write(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failing {
+		return errors.New("write failed")
+	}
+	s.metrics = append(s.metrics, name)
+	return nil
+}
+
+func (s *stubMonitor) names() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.metrics...)
+}
+
+func (s *stubMonitor) Counter(_ context.Context, name string, _ float64, _ map[string]string) error {
+	return s.write(name)
+}
+
+func (s *stubMonitor) Gauge(_ context.Context, name string, _ float64, _ map[string]string) error {
+	return s.write(name)
+}
+
+func (s *stubMonitor) Histogram(_ context.Context, name string, _ float64, _ map[string]string) error {
+	return s.write(name)
+}
+
+func (s *stubMonitor) IsHealthy(context.Context) (bool, error) {
+	s.mutex.Lock()
+	healthy := s.healthy
+	s.mutex.Unlock()
+
+	s.checks <- struct{}{}
+	if !healthy {
+		return false, errors.New("unreachable")
+	}
+	return true, nil
+}
+
+// stubFallback 记录容错策略处理的指标数
+type stubFallback struct {
+	mutex   sync.Mutex
+	handled int
+}
+
+func (f *stubFallback) HandleFailure(context.Context, string, MetricType, float64, map[string]string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.handled++
+	return nil
+}
+
+func (f *stubFallback) IsEnabled() bool {
+	return true
+}
+
+func discardLogger() *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return l
+}
+
+// eventually 等待后台goroutine处理完成后条件成立
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func isHealthy(m *MonitorWithFallback) bool {
+	healthy, _ := m.IsHealthy(context.Background())
+	return healthy
+}
+
+func TestMonitorWithFallbackPeriodicFlipFlop(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	primary := newStubMonitor()
+	fallback := &stubFallback{}
+	m := NewMonitorWithFallback(primary, fallback, 30*time.Second,
+		WithMonitorClock(fake),
+		WithMonitorLogger(discardLogger()),
+	)
+	defer m.Stop()
+
+	// 写入失败时切换到容错策略
+	primary.set(true, true)
+	if err := m.Counter(context.Background(), "requests_total", 1, nil); err != nil {
+		t.Fatalf("fallback should handle the metric: %v", err)
+	}
+	if isHealthy(m) || fallback.handled != 1 {
+		t.Fatalf("healthy=%v handled=%d, want unhealthy with one fallback", isHealthy(m), fallback.handled)
+	}
+
+	// 不健康期间不再尝试主监控系统
+	primary.set(true, false)
+	_ = m.Counter(context.Background(), "requests_total", 1, nil)
+	if len(primary.names()) != 0 || fallback.handled != 2 {
+		t.Fatalf("primary got %v, want metrics routed to fallback while unhealthy", primary.names())
+	}
+
+	// 周期性检查恢复后重新使用主监控系统
+	fake.Advance(30 * time.Second)
+	<-primary.checks
+	eventually(t, func() bool { return isHealthy(m) })
+	_ = m.Gauge(context.Background(), "in_flight", 1, nil)
+	if names := primary.names(); len(names) != 1 || names[0] != "in_flight" {
+		t.Fatalf("primary got %v, want in_flight", names)
+	}
+
+	// 周期性检查失败后再次切换
+	primary.set(false, false)
+	fake.Advance(30 * time.Second)
+	<-primary.checks
+	eventually(t, func() bool { return !isHealthy(m) })
+}
+
+func TestMonitorWithFallbackDampingResetsOnSuccess(t *testing.T) {
+	primary := newStubMonitor()
+	m := NewMonitorWithFallback(primary, &stubFallback{}, 0,
+		WithMonitorLogger(discardLogger()),
+		WithMonitorDamping(healthcheck.DampingConfig{RiseThreshold: 2, FallThreshold: 3, HistorySize: 10}),
+	)
+	defer m.Stop()
+
+	write := func(failing bool) {
+		primary.set(true, failing)
+		_ = m.Counter(context.Background(), "requests_total", 1, nil)
+	}
+
+	// 成功写入之间零星的失败不会累计
+	for i := 0; i < 3; i++ {
+		write(true)
+		write(false)
+	}
+	write(true)
+	write(true)
+	if !isHealthy(m) {
+		t.Fatal("non-consecutive write failures flipped the monitor")
+	}
+
+	// 连续第3次失败才切换
+	write(true)
+	if isHealthy(m) {
+		t.Fatal("3 consecutive write failures did not flip the monitor")
+	}
+	if history := m.History(); len(history) != 1 || history[0].To != healthcheck.StatusDown {
+		t.Fatalf("history %+v, want one transition to DOWN", history)
+	}
+}
+
+func TestRetryBudgetReporterUsesClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	primary := newStubMonitor()
+	budget := retry.NewBudget(retry.BudgetConfig{Ratio: 1, Clock: fake})
+
+	reporter := NewRetryBudgetReporter(primary, "test", budget, 10*time.Second, WithReporterClock(fake))
+	reporter.Start()
+	defer reporter.Stop()
+
+	fake.BlockUntil(1)
+	if len(primary.names()) != 0 {
+		t.Fatalf("reported %v before the first tick", primary.names())
+	}
+
+	fake.Advance(10 * time.Second)
+	eventually(t, func() bool { return len(primary.names()) == 4 })
+}
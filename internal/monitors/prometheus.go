@@ -9,6 +9,8 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/sirupsen/logrus"
 )
 
 // PrometheusMonitor 实现了基于Prometheus的监控
@@ -21,10 +23,21 @@ type PrometheusMonitor struct {
 	endpoint      string
 	server        *http.Server
 	serverStarted bool
+	logger        *logrus.Logger
+}
+
+// PrometheusOption 用于配置 PrometheusMonitor 的可选项
+type PrometheusOption func(*PrometheusMonitor)
+
+// WithPrometheusLogger 设置指标服务器使用的日志器
+func WithPrometheusLogger(l *logrus.Logger) PrometheusOption {
+	return func(p *PrometheusMonitor) {
+		p.logger = l
+	}
 }
 
 // NewPrometheusMonitor 创建新的Prometheus监控
-func NewPrometheusMonitor(endpoint string) *PrometheusMonitor {
+func NewPrometheusMonitor(endpoint string, opts ...PrometheusOption) *PrometheusMonitor {
 	// 创建一个自定义的注册表
 	registry := prometheus.NewRegistry()
 
@@ -32,13 +45,20 @@ func NewPrometheusMonitor(endpoint string) *PrometheusMonitor {
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 	registry.MustRegister(prometheus.NewGoCollector())
 
-	return &PrometheusMonitor{
+	p := &PrometheusMonitor{
 		registry:   registry,
 		counters:   make(map[string]*prometheus.CounterVec),
 		gauges:     make(map[string]*prometheus.GaugeVec),
 		histograms: make(map[string]*prometheus.HistogramVec),
 		endpoint:   endpoint,
+		logger:     logger.Default(),
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 // StartServer 启动Prometheus HTTP服务器以暴露指标
@@ -61,7 +81,7 @@ func (p *PrometheusMonitor) StartServer(port string) error {
 	go func() {
 		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			// 记录错误，但不要中断程序
-			println("prometheus metrics server error:", err.Error())
+			p.logger.WithError(err).WithField("addr", port).Error("Prometheus metrics server error")
 		}
 	}()
 
@@ -0,0 +1,71 @@
+package monitors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+// RetryBudgetReporter 定期将重试预算的状态导出为监控指标
+type RetryBudgetReporter struct {
+	monitor  Monitor
+	budget   *retry.Budget
+	labels   map[string]string
+	interval time.Duration
+	clock    clock.Clock
+	last     retry.BudgetStats
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRetryBudgetReporter 创建一个新的重试预算指标导出器
+func NewRetryBudgetReporter(monitor Monitor, name string, budget *retry.Budget, interval time.Duration, opts ...ReporterOption) *RetryBudgetReporter {
+	o := newReporterOptions(opts)
+	return &RetryBudgetReporter{
+		monitor:  monitor,
+		budget:   budget,
+		labels:   map[string]string{"budget": name},
+		interval: interval,
+		clock:    o.clock,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 开始定期导出
+func (r *RetryBudgetReporter) Start() {
+	go func() {
+		ticker := r.clock.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				r.Report(context.Background())
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Report 导出一次当前状态，计数器只上报自上次导出以来的增量
+func (r *RetryBudgetReporter) Report(ctx context.Context) {
+	stats := r.budget.Stats()
+
+	_ = r.monitor.Gauge(ctx, "retry_budget_tokens", stats.Tokens, r.labels)
+	_ = r.monitor.Counter(ctx, "retry_budget_deposits_total", float64(stats.Deposits-r.last.Deposits), r.labels)
+	_ = r.monitor.Counter(ctx, "retry_budget_withdrawals_total", float64(stats.Withdrawals-r.last.Withdrawals), r.labels)
+	_ = r.monitor.Counter(ctx, "retry_budget_exhausted_total", float64(stats.Rejections-r.last.Rejections), r.labels)
+
+	r.last = stats
+}
+
+// Stop 停止定期导出
+func (r *RetryBudgetReporter) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
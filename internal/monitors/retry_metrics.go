@@ -0,0 +1,80 @@
+package monitors
+
+import (
+	"context"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+// 重试尝试的结果标签
+const (
+	RetryOutcomeSuccess = "success" // 尝试成功
+	RetryOutcomeRetry   = "retry"   // 尝试失败，将会重试
+	RetryOutcomeGiveUp  = "give_up" // 尝试失败，放弃重试
+)
+
+// RetryMetrics 通过重试钩子记录重试指标
+//
+// 导出 retry_attempts_total{operation,outcome} 计数器
+// 和 retry_attempts_per_call{operation} 直方图
+type RetryMetrics struct {
+	monitor Monitor
+}
+
+// NewRetryMetrics 创建重试指标适配器，通常传入 MonitorWithFallback
+func NewRetryMetrics(monitor Monitor) *RetryMetrics {
+	return &RetryMetrics{monitor: monitor}
+}
+
+// Instrument 返回附加了指标钩子的配置副本，原有的钩子会被保留并先执行
+func (r *RetryMetrics) Instrument(config *retry.Config, operation string) *retry.Config {
+	instrumented := *config
+
+	onRetry := config.OnRetry
+	instrumented.OnRetry = func(ctx context.Context, attempt int, err error, nextDelay time.Duration) {
+		if onRetry != nil {
+			onRetry(ctx, attempt, err, nextDelay)
+		}
+		r.recordAttempt(ctx, operation, RetryOutcomeRetry)
+	}
+
+	onGiveUp := config.OnGiveUp
+	instrumented.OnGiveUp = func(ctx context.Context, attempt int, err error) {
+		if onGiveUp != nil {
+			onGiveUp(ctx, attempt, err)
+		}
+		// 第一次尝试前上下文就已取消时没有可记录的尝试
+		if attempt == 0 {
+			return
+		}
+		r.recordAttempt(ctx, operation, RetryOutcomeGiveUp)
+		r.recordCall(ctx, operation, attempt)
+	}
+
+	onSuccess := config.OnSuccess
+	instrumented.OnSuccess = func(ctx context.Context, attempt int) {
+		if onSuccess != nil {
+			onSuccess(ctx, attempt)
+		}
+		r.recordAttempt(ctx, operation, RetryOutcomeSuccess)
+		r.recordCall(ctx, operation, attempt)
+	}
+
+	return &instrumented
+}
+
+// recordAttempt 记录一次尝试的结果
+func (r *RetryMetrics) recordAttempt(ctx context.Context, operation, outcome string) {
+	_ = r.monitor.Counter(ctx, "retry_attempts_total", 1, map[string]string{
+		"operation": operation,
+		"outcome":   outcome,
+	})
+}
+
+// recordCall 记录一次调用总共执行的尝试次数
+func (r *RetryMetrics) recordCall(ctx context.Context, operation string, attempts int) {
+	_ = r.monitor.Histogram(ctx, "retry_attempts_per_call", float64(attempts), map[string]string{
+		"operation": operation,
+	})
+}
@@ -0,0 +1,106 @@
+package monitors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+// metricValue 从监控的私有注册表中读取指定标签的计数器值，或直方图的样本数和总和
+func metricValue(t *testing.T, p *PrometheusMonitor, name string, labels map[string]string) (count uint64, sum float64) {
+	t.Helper()
+
+	families, err := p.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			if len(metric.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			if counter := metric.GetCounter(); counter != nil {
+				return uint64(counter.GetValue()), counter.GetValue()
+			}
+			histogram := metric.GetHistogram()
+			return histogram.GetSampleCount(), histogram.GetSampleSum()
+		}
+	}
+	return 0, 0
+}
+
+func TestRetryMetricsInstrument(t *testing.T) {
+	p := NewPrometheusMonitor("/metrics")
+	errUnavailable := errors.New("unavailable")
+
+	// 原有的钩子保留并先执行
+	var retries, giveUps, successes int
+	config := NewRetryMetrics(p).Instrument(&retry.Config{
+		MaxAttempts: 3,
+		Backoff:     retry.ConstantBackoff{},
+		RetryIf:     func(error) bool { return true },
+		OnRetry:     func(context.Context, int, error, time.Duration) { retries++ },
+		OnGiveUp:    func(context.Context, int, error) { giveUps++ },
+		OnSuccess:   func(context.Context, int) { successes++ },
+	}, "payment")
+
+	// 两次失败后成功
+	calls := 0
+	err := retry.DoWithContext(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errUnavailable
+		}
+		return nil
+	}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 所有尝试都失败
+	err = retry.DoWithContext(context.Background(), func(context.Context) error {
+		return errUnavailable
+	}, config)
+	if !errors.Is(err, retry.ErrMaxRetriesReached) {
+		t.Fatalf("got %v, want ErrMaxRetriesReached", err)
+	}
+
+	// 第一次尝试前上下文已取消，不记录任何尝试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := retry.DoWithContext(ctx, func(context.Context) error { return nil }, config); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	if retries != 4 || giveUps != 2 || successes != 1 {
+		t.Errorf("original hooks called %d/%d/%d times, want 4 retries, 2 give-ups, 1 success", retries, giveUps, successes)
+	}
+
+	for outcome, want := range map[string]uint64{
+		RetryOutcomeRetry:   4,
+		RetryOutcomeSuccess: 1,
+		RetryOutcomeGiveUp:  1,
+	} {
+		got, _ := metricValue(t, p, "retry_attempts_total", map[string]string{"operation": "payment", "outcome": outcome})
+		if got != want {
+			t.Errorf("retry_attempts_total{outcome=%q} = %d, want %d", outcome, got, want)
+		}
+	}
+
+	count, sum := metricValue(t, p, "retry_attempts_per_call", map[string]string{"operation": "payment"})
+	if count != 2 || sum != 6 {
+		t.Errorf("retry_attempts_per_call has %d samples summing to %v, want 2 calls with 6 attempts", count, sum)
+	}
+}
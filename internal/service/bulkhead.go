@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/bulkhead"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+// BulkheadExternalService 为每个外部依赖使用独立的隔离舱限制并发
+type BulkheadExternalService struct {
+	next         ExternalService
+	payment      *bulkhead.Bulkhead
+	notification *bulkhead.Bulkhead
+}
+
+// NewBulkheadExternalService 创建带隔离舱的外部服务，隔离舱为nil的调用不限制并发
+func NewBulkheadExternalService(next ExternalService, payment, notification *bulkhead.Bulkhead) *BulkheadExternalService {
+	return &BulkheadExternalService{
+		next:         next,
+		payment:      payment,
+		notification: notification,
+	}
+}
+
+// ProcessPayment 在支付隔离舱内处理支付
+func (b *BulkheadExternalService) ProcessPayment(ctx context.Context, orderID string, amount float64) error {
+	if b.payment == nil {
+		return b.next.ProcessPayment(ctx, orderID, amount)
+	}
+
+	return bulkheadError(b.payment.Execute(ctx, func(ctx context.Context) error {
+		return b.next.ProcessPayment(ctx, orderID, amount)
+	}))
+}
+
+// SendNotification 在通知隔离舱内发送通知
+func (b *BulkheadExternalService) SendNotification(ctx context.Context, customerID, message string) error {
+	if b.notification == nil {
+		return b.next.SendNotification(ctx, customerID, message)
+	}
+
+	return bulkheadError(b.notification.Execute(ctx, func(ctx context.Context) error {
+		return b.next.SendNotification(ctx, customerID, message)
+	}))
+}
+
+// bulkheadError 将隔离舱拒绝标记为永久性错误，过载时重试只会加剧排队
+func bulkheadError(err error) error {
+	if errors.Is(err, bulkhead.ErrRejected) {
+		return retry.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/circuitbreaker"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+// CircuitBreakerExternalService 在熔断器保护下调用外部服务
+type CircuitBreakerExternalService struct {
+	next         ExternalService
+	payment      *circuitbreaker.Breaker
+	notification *circuitbreaker.Breaker
+}
+
+// NewCircuitBreakerExternalService 创建带熔断保护的外部服务，熔断器为nil的调用不做保护
+func NewCircuitBreakerExternalService(next ExternalService, payment, notification *circuitbreaker.Breaker) *CircuitBreakerExternalService {
+	return &CircuitBreakerExternalService{
+		next:         next,
+		payment:      payment,
+		notification: notification,
+	}
+}
+
+// ProcessPayment 在支付熔断器保护下处理支付
+func (c *CircuitBreakerExternalService) ProcessPayment(ctx context.Context, orderID string, amount float64) error {
+	if c.payment == nil {
+		return c.next.ProcessPayment(ctx, orderID, amount)
+	}
+
+	return breakerError(c.payment.Execute(ctx, func(ctx context.Context) error {
+		return c.next.ProcessPayment(ctx, orderID, amount)
+	}))
+}
+
+// SendNotification 在通知熔断器保护下发送通知
+func (c *CircuitBreakerExternalService) SendNotification(ctx context.Context, customerID, message string) error {
+	if c.notification == nil {
+		return c.next.SendNotification(ctx, customerID, message)
+	}
+
+	return breakerError(c.notification.Execute(ctx, func(ctx context.Context) error {
+		return c.next.SendNotification(ctx, customerID, message)
+	}))
+}
+
+// IsExternalFailure 判断外部服务调用的错误是否应计入熔断器失败
+//
+// 永久性错误（如支付被拒绝）说明下游本身是正常的，不计为失败；
+// 调用方取消由熔断器默认的 IsIgnored 忽略，不会到达这里
+func IsExternalFailure(err error) bool {
+	return err != nil && !retry.IsPermanent(err)
+}
+
+// breakerError 将熔断拒绝标记为永久性错误，熔断期间重试没有意义
+func breakerError(err error) error {
+	if errors.Is(err, circuitbreaker.ErrOpen) || errors.Is(err, circuitbreaker.ErrTooManyProbes) {
+		return retry.Permanent(err)
+	}
+	return err
+}
@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net/http"
 	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+	"github.com/sirupsen/logrus"
 )
 
 // MockExternalService 实现了外部服务接口的模拟版本
@@ -16,18 +22,46 @@ type MockExternalService struct {
 	notificationServiceURL string
 	client                 *http.Client
 	failureRate            float64 // 0.0 - 1.0 之间，表示模拟失败的概率
+	clock                  clock.Clock
+	rand                   clock.Rand
+}
+
+// MockExternalServiceOption 用于配置 MockExternalService 的可选项
+type MockExternalServiceOption func(*MockExternalService)
+
+// WithExternalServiceClock 设置模拟网络延迟使用的时钟
+func WithExternalServiceClock(c clock.Clock) MockExternalServiceOption {
+	return func(m *MockExternalService) {
+		m.clock = c
+	}
+}
+
+// WithExternalServiceRand 设置模拟延迟和失败使用的随机数来源，
+// 服务会被并发调用，随机源需要并发安全
+func WithExternalServiceRand(r clock.Rand) MockExternalServiceOption {
+	return func(m *MockExternalService) {
+		m.rand = r
+	}
 }
 
 // NewMockExternalService 创建一个新的模拟外部服务
-func NewMockExternalService(paymentURL, notificationURL string, timeout time.Duration, failureRate float64) *MockExternalService {
-	return &MockExternalService{
+func NewMockExternalService(paymentURL, notificationURL string, timeout time.Duration, failureRate float64, opts ...MockExternalServiceOption) *MockExternalService {
+	m := &MockExternalService{
 		paymentServiceURL:      paymentURL,
 		notificationServiceURL: notificationURL,
 		client: &http.Client{
 			Timeout: timeout,
+			// 在出站请求中转发请求ID和追踪上下文
+			Transport: requestid.NewTransport(tracing.NewTransport(nil)),
 		},
 		failureRate: failureRate,
+		clock:       clock.Real(),
+		rand:        clock.GlobalRand(),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 // ProcessPayment 模拟处理支付
@@ -40,13 +74,20 @@ func (m *MockExternalService) ProcessPayment(ctx context.Context, orderID string
 		// 继续处理
 	}
 
+	// 金额不合法时支付服务会直接拒绝，属于永久性错误
+	if amount <= 0 {
+		return retry.Permanent(fmt.Errorf("%w: invalid amount %.2f", ErrPaymentDeclined, amount))
+	}
+
 	// 模拟网络延迟
-	delay := rand.Intn(500) + 100
-	time.Sleep(time.Duration(delay) * time.Millisecond)
+	delay := m.intn(500) + 100
+	if err := m.simulateLatency(ctx, time.Duration(delay)*time.Millisecond); err != nil {
+		return err
+	}
 
 	// 模拟随机失败
-	if rand.Float64() < m.failureRate {
-		return simulateExternalServiceError()
+	if m.rand.Float64() < m.failureRate {
+		return m.simulateExternalServiceError()
 	}
 
 	// 实际应用中，这里将发送HTTP请求到实际的支付服务
@@ -54,7 +95,10 @@ func (m *MockExternalService) ProcessPayment(ctx context.Context, orderID string
 	// resp, err := m.client.Do(req)
 	// 处理响应...
 
-	fmt.Printf("Payment processed for order %s: $%.2f\n", orderID, amount)
+	logger.FromContext(ctx, nil).WithFields(logrus.Fields{
+		"order_id": orderID,
+		"amount":   amount,
+	}).Info("Payment processed")
 	return nil
 }
 
@@ -69,12 +113,14 @@ func (m *MockExternalService) SendNotification(ctx context.Context, customerID,
 	}
 
 	// 模拟网络延迟
-	delay := rand.Intn(300) + 50
-	time.Sleep(time.Duration(delay) * time.Millisecond)
+	delay := m.intn(300) + 50
+	if err := m.simulateLatency(ctx, time.Duration(delay)*time.Millisecond); err != nil {
+		return err
+	}
 
 	// 模拟随机失败
-	if rand.Float64() < m.failureRate {
-		return simulateExternalServiceError()
+	if m.rand.Float64() < m.failureRate {
+		return m.simulateExternalServiceError()
 	}
 
 	// 实际应用中，这里将发送HTTP请求到实际的通知服务
@@ -82,12 +128,33 @@ func (m *MockExternalService) SendNotification(ctx context.Context, customerID,
 	// resp, err := m.client.Do(req)
 	// 处理响应...
 
-	fmt.Printf("Notification sent to customer %s: %s\n", customerID, message)
+	logger.FromContext(ctx, nil).WithFields(logrus.Fields{
+		"customer_id": customerID,
+		"message":     message,
+	}).Info("Notification sent")
 	return nil
 }
 
+// intn 返回 [0, n) 区间内的随机整数
+func (m *MockExternalService) intn(n int) int {
+	return int(m.rand.Float64() * float64(n))
+}
+
+// 模拟网络延迟，上下文取消或超时时提前返回
+func (m *MockExternalService) simulateLatency(ctx context.Context, delay time.Duration) error {
+	timer := m.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
 // 模拟可能的外部服务错误类型
-func simulateExternalServiceError() error {
+func (m *MockExternalService) simulateExternalServiceError() error {
 	errors := []error{
 		errors.New("connection refused"),
 		errors.New("timeout exceeded"),
@@ -96,5 +163,5 @@ func simulateExternalServiceError() error {
 		errors.New("bad gateway"),
 	}
 
-	return errors[rand.Intn(len(errors))]
+	return errors[m.intn(len(errors))]
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// fixedRand 总是返回同一个随机数
+type fixedRand float64
+
+func (r fixedRand) Float64() float64 { return float64(r) }
+
+// callAsync 在后台调用 call，并通过通道返回结果
+func callAsync(call func() error) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- call()
+	}()
+	return result
+}
+
+func TestMockExternalServiceLatencyFollowsClock(t *testing.T) {
+	tests := []struct {
+		name  string
+		call  func(context.Context, *MockExternalService) error
+		delay time.Duration
+	}{
+		{
+			name: "payment",
+			call: func(ctx context.Context, m *MockExternalService) error {
+				return m.ProcessPayment(ctx, "order-1", 10)
+			},
+			delay: 350 * time.Millisecond, // 500*0.5 + 100
+		},
+		{
+			name: "notification",
+			call: func(ctx context.Context, m *MockExternalService) error {
+				return m.SendNotification(ctx, "customer-1", "hello")
+			},
+			delay: 200 * time.Millisecond, // 300*0.5 + 50
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Unix(0, 0))
+			m := NewMockExternalService("", "", time.Second, 0.3,
+				WithExternalServiceClock(fake), WithExternalServiceRand(fixedRand(0.5)))
+
+			result := callAsync(func() error { return tt.call(context.Background(), m) })
+			fake.BlockUntil(1)
+
+			fake.Advance(tt.delay - time.Millisecond)
+			select {
+			case err := <-result:
+				t.Fatalf("returned %v before the simulated latency elapsed", err)
+			default:
+			}
+
+			fake.Advance(time.Millisecond)
+			if err := <-result; err != nil {
+				t.Fatalf("got %v, want success", err)
+			}
+		})
+	}
+}
+
+func TestMockExternalServiceFailureRate(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	m := NewMockExternalService("", "", time.Second, 0.6,
+		WithExternalServiceClock(fake), WithExternalServiceRand(fixedRand(0.5)))
+
+	result := callAsync(func() error { return m.ProcessPayment(context.Background(), "order-1", 10) })
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	// 5种错误中取下标 int(5*0.5)
+	if err := <-result; err == nil || err.Error() != "internal server error" {
+		t.Fatalf("got %v, want the simulated internal server error", err)
+	}
+}
+
+func TestMockExternalServiceCancelledDuringLatency(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	m := NewMockExternalService("", "", time.Second, 0,
+		WithExternalServiceClock(fake), WithExternalServiceRand(fixedRand(0.5)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := callAsync(func() error { return m.SendNotification(ctx, "customer-1", "hello") })
+	fake.BlockUntil(1)
+	cancel()
+
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
@@ -2,13 +2,25 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/saixiaoxi/high-availability-system/internal/models"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+	"github.com/saixiaoxi/high-availability-system/pkg/logger"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
 	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrPaymentDeclined 表示支付被支付服务拒绝（业务拒绝，重试无意义）
+	ErrPaymentDeclined = errors.New("payment declined")
 )
 
 // Service 处理业务逻辑
@@ -19,6 +31,45 @@ type Service struct {
 	ordersMutex     sync.RWMutex
 	retryConfig     *retry.Config
 	externalService ExternalService
+
+	paymentRetryConfig      *retry.Config
+	notificationRetryConfig *retry.Config
+	logger                  *logrus.Logger
+
+	// notificationSlots 限制后台发送中（含重试等待）的通知数量
+	maxPendingNotifications int
+	notificationSlots       chan struct{}
+}
+
+// Option 用于配置 Service 的可选项
+type Option func(*Service)
+
+// WithPaymentRetryConfig 为支付调用设置单独的重试配置
+func WithPaymentRetryConfig(config *retry.Config) Option {
+	return func(s *Service) {
+		s.paymentRetryConfig = config
+	}
+}
+
+// WithNotificationRetryConfig 为通知调用设置单独的重试配置
+func WithNotificationRetryConfig(config *retry.Config) Option {
+	return func(s *Service) {
+		s.notificationRetryConfig = config
+	}
+}
+
+// WithMaxPendingNotifications 设置同时在后台发送的通知上限，达到上限时新通知被丢弃
+func WithMaxPendingNotifications(n int) Option {
+	return func(s *Service) {
+		s.maxPendingNotifications = n
+	}
+}
+
+// WithLogger 设置日志器，请求上下文中有请求级日志条目时优先使用
+func WithLogger(l *logrus.Logger) Option {
+	return func(s *Service) {
+		s.logger = l
+	}
 }
 
 // ExternalService 表示外部服务接口
@@ -28,17 +79,34 @@ type ExternalService interface {
 }
 
 // NewService 创建新的服务实例
-func NewService(retryConfig *retry.Config, externalService ExternalService) *Service {
-	return &Service{
-		products:        make(map[string]models.Product),
-		orders:          make(map[string]models.Order),
-		retryConfig:     retryConfig,
-		externalService: externalService,
+func NewService(retryConfig *retry.Config, externalService ExternalService, opts ...Option) *Service {
+	s := &Service{
+		products:                make(map[string]models.Product),
+		orders:                  make(map[string]models.Order),
+		retryConfig:             retryConfig,
+		externalService:         externalService,
+		paymentRetryConfig:      retryConfig,
+		notificationRetryConfig: retryConfig,
+		logger:                  logger.Default(),
+		maxPendingNotifications: 100,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxPendingNotifications <= 0 {
+		s.maxPendingNotifications = 1
 	}
+	s.notificationSlots = make(chan struct{}, s.maxPendingNotifications)
+
+	return s
 }
 
 // GetAllProducts 获取所有产品
 func (s *Service) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	_, span := tracing.Start(ctx, "Service.GetAllProducts")
+	defer span.End()
+
 	s.productsMutex.RLock()
 	defer s.productsMutex.RUnlock()
 
@@ -51,6 +119,9 @@ func (s *Service) GetAllProducts(ctx context.Context) ([]models.Product, error)
 
 // GetProductByID 根据ID获取产品
 func (s *Service) GetProductByID(ctx context.Context, id string) (models.Product, error) {
+	_, span := tracing.Start(ctx, "Service.GetProductByID")
+	defer span.End()
+
 	s.productsMutex.RLock()
 	defer s.productsMutex.RUnlock()
 
@@ -63,6 +134,9 @@ func (s *Service) GetProductByID(ctx context.Context, id string) (models.Product
 
 // CreateProduct 创建新产品
 func (s *Service) CreateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	_, span := tracing.Start(ctx, "Service.CreateProduct")
+	defer span.End()
+
 	s.productsMutex.Lock()
 	defer s.productsMutex.Unlock()
 
@@ -74,6 +148,9 @@ func (s *Service) CreateProduct(ctx context.Context, product models.Product) (mo
 
 // UpdateProduct 更新产品
 func (s *Service) UpdateProduct(ctx context.Context, product models.Product) (models.Product, error) {
+	_, span := tracing.Start(ctx, "Service.UpdateProduct")
+	defer span.End()
+
 	s.productsMutex.Lock()
 	defer s.productsMutex.Unlock()
 
@@ -89,6 +166,9 @@ func (s *Service) UpdateProduct(ctx context.Context, product models.Product) (mo
 
 // DeleteProduct 删除产品
 func (s *Service) DeleteProduct(ctx context.Context, id string) error {
+	_, span := tracing.Start(ctx, "Service.DeleteProduct")
+	defer span.End()
+
 	s.productsMutex.Lock()
 	defer s.productsMutex.Unlock()
 
@@ -103,6 +183,9 @@ func (s *Service) DeleteProduct(ctx context.Context, id string) error {
 
 // GetAllOrders 获取所有订单
 func (s *Service) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+	_, span := tracing.Start(ctx, "Service.GetAllOrders")
+	defer span.End()
+
 	s.ordersMutex.RLock()
 	defer s.ordersMutex.RUnlock()
 
@@ -115,6 +198,9 @@ func (s *Service) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 
 // GetOrderByID 根据ID获取订单
 func (s *Service) GetOrderByID(ctx context.Context, id string) (models.Order, error) {
+	_, span := tracing.Start(ctx, "Service.GetOrderByID")
+	defer span.End()
+
 	s.ordersMutex.RLock()
 	defer s.ordersMutex.RUnlock()
 
@@ -127,6 +213,9 @@ func (s *Service) GetOrderByID(ctx context.Context, id string) (models.Order, er
 
 // CreateOrder 创建新订单，带有重试机制处理外部服务调用
 func (s *Service) CreateOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateOrder")
+	defer span.End()
+
 	// 计算总价
 	var totalPrice float64
 	for _, item := range order.Items {
@@ -134,14 +223,33 @@ func (s *Service) CreateOrder(ctx context.Context, order models.Order) (models.O
 	}
 	order.TotalPrice = totalPrice
 
-	// 生成订单ID (实际应用中应使用UUID等)
-	order.ID = fmt.Sprintf("order-%d", time.Now().UnixNano())
+	// 携带幂等键时订单ID由幂等键确定，同一个键的重试使用同一个订单，
+	// 已支付的订单直接返回，支付失败的订单以相同订单ID重新支付，避免重复下单和扣款
+	if key := idempotency.KeyFromContext(ctx); key != "" {
+		order.ID = idempotentOrderID(order.CustomerID, key)
+
+		s.ordersMutex.RLock()
+		existing, exists := s.orders[order.ID]
+		s.ordersMutex.RUnlock()
+		if exists && existing.Status == models.OrderStatusPaid {
+			span.AddEvent("order.deduplicated", tracing.Attr("order.id", existing.ID))
+			return existing, nil
+		}
+	} else {
+		// 生成订单ID (实际应用中应使用UUID等)
+		order.ID = fmt.Sprintf("order-%d", time.Now().UnixNano())
+	}
 	order.Status = models.OrderStatusPending
+	span.SetAttributes(tracing.Attr("order.id", order.ID), tracing.Attr("order.total_price", order.TotalPrice))
 
-	// 处理支付，带有重试机制
+	// 处理支付，带有重试机制；业务拒绝不重试
+	paymentRetryConfig := *s.paymentRetryConfig
+	paymentRetryConfig.RetryIf = func(err error) bool {
+		return !errors.Is(err, ErrPaymentDeclined)
+	}
 	err := retry.DoWithContext(ctx, func(ctx context.Context) error {
 		return s.externalService.ProcessPayment(ctx, order.ID, order.TotalPrice)
-	}, s.retryConfig)
+	}, &paymentRetryConfig)
 
 	if err != nil {
 		order.Status = models.OrderStatusCancelled
@@ -149,7 +257,9 @@ func (s *Service) CreateOrder(ctx context.Context, order models.Order) (models.O
 		s.ordersMutex.Lock()
 		s.orders[order.ID] = order
 		s.ordersMutex.Unlock()
-		return order, errors.New("payment processing failed after retries: " + err.Error())
+		span.RecordError(err)
+		logger.FromContext(ctx, s.logger).WithError(err).WithField("order_id", order.ID).Warn("Payment processing failed")
+		return order, fmt.Errorf("payment processing failed: %w", err)
 	}
 
 	// 支付成功
@@ -160,18 +270,53 @@ func (s *Service) CreateOrder(ctx context.Context, order models.Order) (models.O
 	s.orders[order.ID] = order
 	s.ordersMutex.Unlock()
 
+	logger.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"order_id":    order.ID,
+		"total_price": order.TotalPrice,
+	}).Info("Order created")
+
 	// 发送通知，使用重试机制
+	// 重试等待发生在隔离舱之外，因此先占用一个后台名额再启动goroutine；
+	// 名额用尽（通常是通知服务故障导致积压）时丢弃通知，不影响下单
+	select {
+	case s.notificationSlots <- struct{}{}:
+	default:
+		span.AddEvent("notification.dropped")
+		logger.FromContext(ctx, s.logger).WithField("order_id", order.ID).Warn("Too many pending notifications, dropping order notification")
+		return order, nil
+	}
+
+	// 通知在请求结束后继续执行，不能沿用请求上下文，需要显式带上请求ID和追踪标识
+	requestID := requestid.FromContext(ctx)
+	spanContext := tracing.SpanContextFromContext(ctx)
 	go func() {
-		notificationCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer func() { <-s.notificationSlots }()
+
+		notificationCtx := requestid.NewContext(context.Background(), requestID)
+		notificationCtx = tracing.ContextWithSpanContext(notificationCtx, spanContext)
+		notificationCtx, cancel := context.WithTimeout(notificationCtx, 30*time.Second)
 		defer cancel()
 
+		notificationCtx, span := tracing.Start(notificationCtx, "Service.SendOrderNotification")
+		defer span.End()
+
 		notificationMessage := fmt.Sprintf("Your order %s has been successfully processed.", order.ID)
 
 		// 使用重试机制发送通知
-		_ = retry.DoWithContext(notificationCtx, func(ctx context.Context) error {
+		err := retry.DoWithContext(notificationCtx, func(ctx context.Context) error {
 			return s.externalService.SendNotification(ctx, order.CustomerID, notificationMessage)
-		}, s.retryConfig)
+		}, s.notificationRetryConfig)
+		if err != nil {
+			span.RecordError(err)
+			logger.FromContext(notificationCtx, s.logger).WithError(err).WithField("order_id", order.ID).Warn("Failed to send order notification")
+		}
 	}()
 
 	return order, nil
 }
+
+// idempotentOrderID 根据客户ID和幂等键生成确定的订单ID，不同客户使用相同的键不会冲突
+func idempotentOrderID(customerID, key string) string {
+	sum := sha256.Sum256([]byte(customerID + "\x00" + key))
+	return "order-" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/internal/models"
+	"github.com/saixiaoxi/high-availability-system/pkg/idempotency"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+	"github.com/sirupsen/logrus"
+)
+
+// blockingNotifier 的通知调用阻塞到 release 关闭为止
+type blockingNotifier struct {
+	notifications atomic.Int32
+	started       chan struct{}
+	release       chan struct{}
+}
+
+func (n *blockingNotifier) ProcessPayment(context.Context, string, float64) error {
+	return nil
+}
+
+func (n *blockingNotifier) SendNotification(ctx context.Context, _, _ string) error {
+	n.notifications.Add(1)
+	n.started <- struct{}{}
+	<-n.release
+	return nil
+}
+
+func TestCreateOrderBoundsPendingNotifications(t *testing.T) {
+	notifier := &blockingNotifier{started: make(chan struct{}, 4), release: make(chan struct{})}
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	svc := NewService(&retry.Config{MaxAttempts: 1}, notifier,
+		WithMaxPendingNotifications(1),
+		WithLogger(l),
+	)
+
+	order := models.Order{CustomerID: "c-1", Items: []models.OrderItem{{ProductID: "p-1", Quantity: 1, Price: 10}}}
+	for i := 0; i < 3; i++ {
+		if _, err := svc.CreateOrder(context.Background(), order); err != nil {
+			t.Fatalf("order %d failed: %v", i, err)
+		}
+	}
+
+	<-notifier.started
+	if got := notifier.notifications.Load(); got != 1 {
+		t.Fatalf("%d notifications started, want 1 while the only slot is busy", got)
+	}
+
+	// 名额归还后新的通知可以再次发送
+	close(notifier.release)
+	// 等待后台goroutine退出并归还名额
+	for len(svc.notificationSlots) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := svc.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("order failed: %v", err)
+	}
+	<-notifier.started
+	if got := notifier.notifications.Load(); got != 2 {
+		t.Fatalf("%d notifications started, want 2 after the slot was released", got)
+	}
+}
+
+// countingPayments 记录支付调用，前 fail 次返回错误
+type countingPayments struct {
+	orderIDs []string
+	fail     int
+}
+
+func (p *countingPayments) ProcessPayment(_ context.Context, orderID string, _ float64) error {
+	p.orderIDs = append(p.orderIDs, orderID)
+	if len(p.orderIDs) <= p.fail {
+		return errors.New("payment gateway unavailable")
+	}
+	return nil
+}
+
+func (p *countingPayments) SendNotification(context.Context, string, string) error {
+	return nil
+}
+
+func TestCreateOrderHonorsIdempotencyKey(t *testing.T) {
+	payments := &countingPayments{fail: 1}
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	svc := NewService(&retry.Config{MaxAttempts: 1}, payments, WithLogger(l))
+
+	order := models.Order{CustomerID: "c-1", Items: []models.OrderItem{{ProductID: "p-1", Quantity: 1, Price: 10}}}
+	ctx := idempotency.NewContext(context.Background(), "k-1")
+
+	// 支付失败后用同一个键重试，使用同一个订单ID重新支付
+	if _, err := svc.CreateOrder(ctx, order); err == nil {
+		t.Fatal("first attempt should fail")
+	}
+	created, err := svc.CreateOrder(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已支付的订单直接返回，不再扣款
+	again, err := svc.CreateOrder(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != created.ID || again.Status != models.OrderStatusPaid {
+		t.Fatalf("got %+v, want the paid order %s", again, created.ID)
+	}
+	if len(payments.orderIDs) != 2 || payments.orderIDs[0] != created.ID || payments.orderIDs[1] != created.ID {
+		t.Fatalf("payments %v, want two calls for order %s", payments.orderIDs, created.ID)
+	}
+	if orders, _ := svc.GetAllOrders(context.Background()); len(orders) != 1 {
+		t.Fatalf("%d orders stored, want 1", len(orders))
+	}
+
+	// 其他客户使用相同的键创建各自的订单
+	other := order
+	other.CustomerID = "c-2"
+	if created2, _ := svc.CreateOrder(ctx, other); created2.ID == created.ID {
+		t.Fatal("different customers share an order for the same key")
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+)
+
+// TracingExternalService 为每次外部服务调用创建客户端跨度
+type TracingExternalService struct {
+	next ExternalService
+}
+
+// NewTracingExternalService 创建带追踪的外部服务
+func NewTracingExternalService(next ExternalService) *TracingExternalService {
+	return &TracingExternalService{next: next}
+}
+
+// ProcessPayment 在跨度中处理支付
+func (t *TracingExternalService) ProcessPayment(ctx context.Context, orderID string, amount float64) error {
+	ctx, span := tracing.Start(ctx, "payment-service.ProcessPayment",
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(
+			tracing.Attr("peer.service", "payment-service"),
+			tracing.Attr("order.id", orderID),
+			tracing.Attr("payment.amount", amount),
+		),
+	)
+	defer span.End()
+
+	err := t.next.ProcessPayment(ctx, orderID, amount)
+	span.RecordError(err)
+	return err
+}
+
+// SendNotification 在跨度中发送通知
+func (t *TracingExternalService) SendNotification(ctx context.Context, customerID, message string) error {
+	ctx, span := tracing.Start(ctx, "notification-service.SendNotification",
+		tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(
+			tracing.Attr("peer.service", "notification-service"),
+			tracing.Attr("customer.id", customerID),
+		),
+	)
+	defer span.End()
+
+	err := t.next.SendNotification(ctx, customerID, message)
+	span.RecordError(err)
+	return err
+}
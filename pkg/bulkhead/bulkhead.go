@@ -0,0 +1,176 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var (
+	// ErrRejected 表示请求被隔离舱拒绝，可用 errors.Is 判断任何 RejectedError
+	ErrRejected = errors.New("bulkhead rejected")
+)
+
+// Reason 表示请求被拒绝的原因
+type Reason string
+
+const (
+	// ReasonQueueFull 等待队列已满
+	ReasonQueueFull Reason = "queue_full"
+	// ReasonQueueTimeout 在队列中等待超时
+	ReasonQueueTimeout Reason = "queue_timeout"
+)
+
+// RejectedError 是隔离舱拒绝请求时返回的错误
+type RejectedError struct {
+	Bulkhead string // 隔离舱名称
+	Reason   Reason // 拒绝原因
+}
+
+// Error 返回错误信息
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("bulkhead %s rejected: %s", e.Bulkhead, e.Reason)
+}
+
+// Is 使 errors.Is(err, ErrRejected) 成立
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Config 保存隔离舱配置
+type Config struct {
+	Name          string        // 隔离舱名称，用于指标和错误信息
+	MaxConcurrent int           // 最大并发数
+	MaxQueue      int           // 等待队列长度，为0时不排队
+	QueueTimeout  time.Duration // 在队列中的最长等待时间，为0时只受上下文限制
+	Clock         clock.Clock   // 时钟，为nil时使用系统时钟
+}
+
+// Stats 是隔离舱的统计快照，拒绝次数为累计值
+type Stats struct {
+	InFlight   int
+	Queued     int
+	Rejections map[Reason]uint64
+}
+
+// Bulkhead 限制对某个依赖的并发调用，避免慢依赖耗尽共享资源
+type Bulkhead struct {
+	config     Config
+	slots      chan struct{}
+	queued     int
+	rejections map[Reason]uint64
+	mutex      sync.Mutex
+}
+
+// New 创建新的隔离舱
+func New(config Config) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 1
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+
+	return &Bulkhead{
+		config:     config,
+		slots:      make(chan struct{}, config.MaxConcurrent),
+		rejections: make(map[Reason]uint64),
+	}
+}
+
+// Name 返回隔离舱名称
+func (b *Bulkhead) Name() string {
+	return b.config.Name
+}
+
+// Execute 在隔离舱内执行fn
+func (b *Bulkhead) Execute(ctx context.Context, fn func(context.Context) error) error {
+	release, err := b.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn(ctx)
+}
+
+// Acquire 获取一个并发槽位，成功时返回的 release 必须调用一次以归还槽位
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	// 有空闲槽位时直接获取
+	select {
+	case b.slots <- struct{}{}:
+		return b.releaseFunc(), nil
+	default:
+	}
+
+	// 进入等待队列
+	b.mutex.Lock()
+	if b.queued >= b.config.MaxQueue {
+		b.mutex.Unlock()
+		return nil, b.reject(ReasonQueueFull)
+	}
+	b.queued++
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		b.queued--
+		b.mutex.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if b.config.QueueTimeout > 0 {
+		timer := b.config.Clock.NewTimer(b.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.releaseFunc(), nil
+	case <-timeout:
+		return nil, b.reject(ReasonQueueTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stats 返回当前的统计快照
+func (b *Bulkhead) Stats() Stats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	rejections := make(map[Reason]uint64, len(b.rejections))
+	for reason, count := range b.rejections {
+		rejections[reason] = count
+	}
+
+	return Stats{
+		InFlight:   len(b.slots),
+		Queued:     b.queued,
+		Rejections: rejections,
+	}
+}
+
+// releaseFunc 返回只生效一次的槽位归还函数
+func (b *Bulkhead) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-b.slots
+		})
+	}
+}
+
+// reject 记录一次拒绝并返回对应的错误
+func (b *Bulkhead) reject(reason Reason) error {
+	b.mutex.Lock()
+	b.rejections[reason]++
+	b.mutex.Unlock()
+
+	return &RejectedError{Bulkhead: b.config.Name, Reason: reason}
+}
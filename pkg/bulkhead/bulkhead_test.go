@@ -0,0 +1,178 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// mustAcquire 获取一个槽位，失败时终止测试
+func mustAcquire(t *testing.T, b *Bulkhead) func() {
+	t.Helper()
+
+	release, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+// waitQueued 等待队列中有n个请求
+func waitQueued(t *testing.T, b *Bulkhead, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for b.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d queued, want %d", b.Stats().Queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBulkheadRejectsWhenQueueFull(t *testing.T) {
+	b := New(Config{Name: "payment", MaxConcurrent: 1})
+	release := mustAcquire(t, b)
+
+	_, err := b.Acquire(context.Background())
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != ReasonQueueFull || rejected.Bulkhead != "payment" {
+		t.Fatalf("got %v, want a queue_full rejection", err)
+	}
+	if !errors.Is(err, ErrRejected) {
+		t.Fatal("rejection does not match ErrRejected")
+	}
+
+	// 归还槽位后可以再次获取，重复归还无效
+	release()
+	release()
+	mustAcquire(t, b)()
+	if stats := b.Stats(); stats.InFlight != 0 {
+		t.Fatalf("%d in flight after release, want 0", stats.InFlight)
+	}
+}
+
+func TestBulkheadQueuedRequestGetsReleasedSlot(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1, MaxQueue: 1})
+	release := mustAcquire(t, b)
+
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	waitQueued(t, b, 1)
+
+	// 队列已满时新请求立即被拒绝
+	if _, err := b.Acquire(context.Background()); !errors.Is(err, ErrRejected) {
+		t.Fatalf("got %v, want rejection while the queue is full", err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("queued request got %v, want the released slot", err)
+	}
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := New(Config{Name: "notification", MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second, Clock: fake})
+	defer mustAcquire(t, b)()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := b.Acquire(context.Background())
+		result <- err
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	var rejected *RejectedError
+	if err := <-result; !errors.As(err, &rejected) || rejected.Reason != ReasonQueueTimeout {
+		t.Fatalf("got %v, want a queue_timeout rejection", err)
+	}
+	if stats := b.Stats(); stats.Queued != 0 {
+		t.Fatalf("%d still queued after timeout, want 0", stats.Queued)
+	}
+}
+
+func TestBulkheadContextCanceledWhileQueued(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1, MaxQueue: 1})
+	defer mustAcquire(t, b)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := b.Acquire(ctx)
+		result <- err
+	}()
+	waitQueued(t, b, 1)
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) || errors.Is(err, ErrRejected) {
+		t.Fatalf("got %v, want context.Canceled rather than a rejection", err)
+	}
+
+	// 取消不计入拒绝次数
+	stats := b.Stats()
+	if stats.Queued != 0 || len(stats.Rejections) != 0 {
+		t.Fatalf("got %+v, want an empty queue and no rejections", stats)
+	}
+}
+
+func TestBulkheadStats(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := New(Config{MaxConcurrent: 2, MaxQueue: 1, QueueTimeout: time.Second, Clock: fake})
+
+	release1 := mustAcquire(t, b)
+	release2 := mustAcquire(t, b)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := b.Acquire(context.Background())
+		result <- err
+	}()
+	fake.BlockUntil(1)
+
+	_, _ = b.Acquire(context.Background())
+	_, _ = b.Acquire(context.Background())
+
+	stats := b.Stats()
+	if stats.InFlight != 2 || stats.Queued != 1 || stats.Rejections[ReasonQueueFull] != 2 {
+		t.Fatalf("got %+v, want 2 in flight, 1 queued and 2 queue_full rejections", stats)
+	}
+
+	fake.Advance(time.Second)
+	<-result
+	release1()
+	release2()
+
+	stats = b.Stats()
+	if stats.InFlight != 0 || stats.Queued != 0 || stats.Rejections[ReasonQueueFull] != 2 || stats.Rejections[ReasonQueueTimeout] != 1 {
+		t.Fatalf("got %+v, want cumulative rejections and nothing in flight", stats)
+	}
+}
+
+func TestBulkheadExecute(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1})
+
+	errFailed := errors.New("failed")
+	if err := b.Execute(context.Background(), func(context.Context) error {
+		if b.Stats().InFlight != 1 {
+			t.Error("Execute runs outside a slot")
+		}
+		return errFailed
+	}); !errors.Is(err, errFailed) {
+		t.Fatalf("got %v, want the function's error", err)
+	}
+	if b.Stats().InFlight != 0 {
+		t.Fatal("Execute did not release its slot")
+	}
+}
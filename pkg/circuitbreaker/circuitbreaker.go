@@ -0,0 +1,311 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var (
+	// ErrOpen 表示熔断器处于打开状态，调用被拒绝
+	ErrOpen = errors.New("circuit breaker is open")
+	// ErrTooManyProbes 表示半开状态下的探测请求数已达上限
+	ErrTooManyProbes = errors.New("circuit breaker half-open probe limit reached")
+)
+
+// State 表示熔断器状态
+type State int
+
+const (
+	// StateClosed 正常放行所有请求
+	StateClosed State = iota
+	// StateHalfOpen 放行有限的探测请求，根据结果决定关闭或重新打开
+	StateHalfOpen
+	// StateOpen 拒绝所有请求，直到打开超时结束
+	StateOpen
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config 保存熔断器配置
+type Config struct {
+	Name string // 熔断器名称，用于指标和健康检查
+
+	FailureRateThreshold float64       // 窗口内失败率达到该值时打开 (0-1)，为0时不按失败率熔断
+	MinRequests          int           // 按失败率判断前窗口内至少需要的请求数
+	Window               time.Duration // 统计失败率的滑动窗口长度
+	WindowBuckets        int           // 滑动窗口的桶数量
+	ConsecutiveFailures  int           // 连续失败达到该次数时打开，为0时不按连续失败熔断
+
+	OpenTimeout       time.Duration // 打开状态持续多久后进入半开状态
+	HalfOpenMaxProbes int           // 半开状态下允许同时进行的探测请求数，也是关闭所需的连续成功次数
+
+	// IsIgnored 判断一次调用的结果是否忽略，忽略的结果只释放半开状态的探测名额，
+	// 不计为成功或失败；为nil时忽略 context.Canceled
+	IsIgnored func(error) bool
+	// IsFailure 判断未被忽略的错误是否计为失败，为nil时所有非nil错误都计为失败
+	IsFailure func(error) bool
+	// OnStateChange 在状态变化时调用，调用时持有熔断器内部锁，不能再调用熔断器的方法
+	OnStateChange func(name string, from, to State)
+	// Clock 时钟，为nil时使用系统时钟
+	Clock clock.Clock
+}
+
+// DefaultConfig 返回默认熔断器配置
+func DefaultConfig(name string) Config {
+	return Config{
+		Name:                 name,
+		FailureRateThreshold: 0.5,
+		MinRequests:          10,
+		Window:               30 * time.Second,
+		WindowBuckets:        10,
+		ConsecutiveFailures:  5,
+		OpenTimeout:          15 * time.Second,
+		HalfOpenMaxProbes:    3,
+	}
+}
+
+// Counts 是熔断器当前的统计快照
+type Counts struct {
+	Requests            int // 窗口内的请求数
+	Failures            int // 窗口内的失败数
+	ConsecutiveFailures int // 当前连续失败次数
+}
+
+// Breaker 是一个熔断器，可在多个goroutine间共享
+type Breaker struct {
+	config Config
+	clock  clock.Clock
+	window *window
+	mutex  sync.Mutex
+
+	state               State
+	generation          uint64 // 每次状态变化时递增，用于忽略旧状态下发出的请求结果
+	openedAt            time.Time
+	consecutiveFailures int
+	probes              int // 半开状态下正在进行的探测请求数
+	probeSuccesses      int // 半开状态下连续成功的探测请求数
+}
+
+// New 创建新的熔断器
+func New(config Config) *Breaker {
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.IsIgnored == nil {
+		config.IsIgnored = defaultIsIgnored
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = defaultIsFailure
+	}
+	if config.Window <= 0 {
+		config.Window = 30 * time.Second
+	}
+	if config.WindowBuckets <= 0 {
+		config.WindowBuckets = 10
+	}
+	if config.HalfOpenMaxProbes <= 0 {
+		config.HalfOpenMaxProbes = 1
+	}
+
+	return &Breaker{
+		config: config,
+		clock:  config.Clock,
+		window: newWindow(config.Window, config.WindowBuckets),
+		state:  StateClosed,
+	}
+}
+
+// Name 返回熔断器名称
+func (b *Breaker) Name() string {
+	return b.config.Name
+}
+
+// State 返回熔断器当前状态
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refreshState(b.clock.Now())
+	return b.state
+}
+
+// Counts 返回当前的统计快照
+func (b *Breaker) Counts() Counts {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	requests, failures := b.window.totals(b.clock.Now())
+	return Counts{
+		Requests:            requests,
+		Failures:            failures,
+		ConsecutiveFailures: b.consecutiveFailures,
+	}
+}
+
+// Execute 在熔断器保护下执行fn，熔断时直接返回 ErrOpen 或 ErrTooManyProbes
+func (b *Breaker) Execute(ctx context.Context, fn func(context.Context) error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	// fn panic 时计为失败后继续向上抛出
+	defer func() {
+		if r := recover(); r != nil {
+			done(errors.New("panic"))
+			panic(r)
+		}
+	}()
+
+	err = fn(ctx)
+	done(err)
+	return err
+}
+
+// Allow 检查是否允许一次请求，允许时返回的 done 必须在请求结束后以其结果调用一次
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	b.refreshState(now)
+
+	switch b.state {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.config.HalfOpenMaxProbes {
+			return nil, ErrTooManyProbes
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.record(generation, err)
+		})
+	}, nil
+}
+
+// record 记录一次请求结果
+func (b *Breaker) record(generation uint64, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	b.refreshState(now)
+
+	// 请求发出后状态已变化，结果不再有参考意义
+	if generation != b.generation {
+		return
+	}
+
+	// 被忽略的结果（如调用方取消）不能说明下游是否健康，只归还探测名额
+	if b.config.IsIgnored(err) {
+		if b.state == StateHalfOpen {
+			b.probes--
+		}
+		return
+	}
+
+	failed := b.config.IsFailure(err)
+
+	switch b.state {
+	case StateClosed:
+		b.window.record(now, failed)
+		if failed {
+			b.consecutiveFailures++
+		} else {
+			b.consecutiveFailures = 0
+		}
+		if b.shouldTrip(now) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenMaxProbes {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// shouldTrip 判断关闭状态下是否应该打开熔断器，调用方需持有锁
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	if b.config.ConsecutiveFailures > 0 && b.consecutiveFailures >= b.config.ConsecutiveFailures {
+		return true
+	}
+
+	if b.config.FailureRateThreshold <= 0 {
+		return false
+	}
+	requests, failures := b.window.totals(now)
+	if requests == 0 || requests < b.config.MinRequests {
+		return false
+	}
+	return float64(failures)/float64(requests) >= b.config.FailureRateThreshold
+}
+
+// refreshState 打开超时结束后切换到半开状态，调用方需持有锁
+func (b *Breaker) refreshState(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+// setState 切换状态并重置相关计数，调用方需持有锁
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.generation++
+	b.consecutiveFailures = 0
+	b.probes = 0
+	b.probeSuccesses = 0
+
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset()
+	}
+
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.config.Name, from, state)
+	}
+}
+
+// defaultIsIgnored 忽略调用方主动取消的请求
+func defaultIsIgnored(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// defaultIsFailure 所有错误都计为失败
+func defaultIsFailure(err error) bool {
+	return err != nil
+}
@@ -0,0 +1,191 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var errDownstream = errors.New("downstream failed")
+
+func newTestBreaker(fake *clock.Fake, config Config) *Breaker {
+	config.Name = "test"
+	config.Clock = fake
+	if config.OpenTimeout == 0 {
+		config.OpenTimeout = 10 * time.Second
+	}
+	return New(config)
+}
+
+// call 在熔断器保护下执行一次返回 err 的调用
+func call(b *Breaker, err error) error {
+	return b.Execute(context.Background(), func(context.Context) error {
+		return err
+	})
+}
+
+func TestBreakerConsecutiveFailuresFlipFlop(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var transitions []string
+	b := newTestBreaker(fake, Config{
+		ConsecutiveFailures: 2,
+		HalfOpenMaxProbes:   1,
+		OnStateChange: func(_ string, from, to State) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+
+	_ = call(b, errDownstream)
+	_ = call(b, errDownstream)
+	if b.State() != StateOpen {
+		t.Fatalf("state %v after 2 failures, want open", b.State())
+	}
+	if err := call(b, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("got %v while open, want ErrOpen", err)
+	}
+
+	// 打开超时结束前仍然拒绝
+	fake.Advance(9 * time.Second)
+	if b.State() != StateOpen {
+		t.Fatalf("state %v before open timeout, want open", b.State())
+	}
+
+	// 半开状态下探测失败重新打开
+	fake.Advance(time.Second)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state %v after open timeout, want half_open", b.State())
+	}
+	_ = call(b, errDownstream)
+	if b.State() != StateOpen {
+		t.Fatalf("state %v after failed probe, want open", b.State())
+	}
+
+	// 再次超时后探测成功关闭
+	fake.Advance(10 * time.Second)
+	if err := call(b, nil); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("state %v after successful probe, want closed", b.State())
+	}
+
+	want := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions %v, want %v", transitions, want)
+		}
+	}
+}
+
+func TestBreakerSuccessResetsConsecutiveFailures(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{ConsecutiveFailures: 2})
+
+	_ = call(b, errDownstream)
+	_ = call(b, nil)
+	_ = call(b, errDownstream)
+	if b.State() != StateClosed {
+		t.Fatalf("state %v, want closed when failures are not consecutive", b.State())
+	}
+}
+
+func TestBreakerFailureRate(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{
+		FailureRateThreshold: 0.5,
+		MinRequests:          4,
+		Window:               10 * time.Second,
+		WindowBuckets:        10,
+	})
+
+	_ = call(b, errDownstream)
+	_ = call(b, nil)
+	_ = call(b, errDownstream)
+	if b.State() != StateClosed {
+		t.Fatalf("state %v below MinRequests, want closed", b.State())
+	}
+	_ = call(b, nil)
+	if b.State() != StateOpen {
+		t.Fatalf("state %v at 50%% failure rate, want open", b.State())
+	}
+}
+
+func TestBreakerFailuresExpireFromWindow(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{
+		FailureRateThreshold: 0.5,
+		MinRequests:          2,
+		Window:               10 * time.Second,
+		WindowBuckets:        10,
+	})
+
+	_ = call(b, errDownstream)
+	fake.Advance(11 * time.Second)
+	_ = call(b, nil)
+	_ = call(b, nil)
+	if counts := b.Counts(); counts.Failures != 0 || counts.Requests != 2 {
+		t.Fatalf("counts %+v, want the old failure to have expired", counts)
+	}
+}
+
+func TestBreakerHalfOpenProbeLimit(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{ConsecutiveFailures: 1, HalfOpenMaxProbes: 1})
+
+	_ = call(b, errDownstream)
+	fake.Advance(10 * time.Second)
+
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("first probe rejected: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrTooManyProbes) {
+		t.Fatalf("got %v for second probe, want ErrTooManyProbes", err)
+	}
+	done(nil)
+	if b.State() != StateClosed {
+		t.Fatalf("state %v, want closed", b.State())
+	}
+}
+
+func TestBreakerCanceledProbeIsIgnored(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{ConsecutiveFailures: 2, HalfOpenMaxProbes: 1})
+
+	_ = call(b, errDownstream)
+	_ = call(b, errDownstream)
+	fake.Advance(10 * time.Second)
+
+	// 取消的探测不能关闭熔断器，但要归还探测名额
+	_ = call(b, context.Canceled)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("state %v after canceled probe, want half_open", b.State())
+	}
+	if err := call(b, nil); err != nil {
+		t.Fatalf("probe slot not released: %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("state %v after successful probe, want closed", b.State())
+	}
+}
+
+func TestBreakerCanceledDoesNotResetCounts(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := newTestBreaker(fake, Config{ConsecutiveFailures: 2})
+
+	_ = call(b, errDownstream)
+	_ = call(b, context.Canceled)
+	if counts := b.Counts(); counts.ConsecutiveFailures != 1 || counts.Requests != 1 {
+		t.Fatalf("counts %+v, want the canceled call to be ignored", counts)
+	}
+	_ = call(b, errDownstream)
+	if b.State() != StateOpen {
+		t.Fatalf("state %v, want open after 2 consecutive failures", b.State())
+	}
+}
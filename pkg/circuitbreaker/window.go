@@ -0,0 +1,69 @@
+package circuitbreaker
+
+import "time"
+
+// bucket 保存滑动窗口中一个时间片的统计
+type bucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// window 是按时间分桶的滑动窗口
+type window struct {
+	buckets    []bucket
+	bucketSize time.Duration
+	length     time.Duration
+}
+
+// newWindow 创建长度为 length、分为n个桶的滑动窗口
+func newWindow(length time.Duration, n int) *window {
+	bucketSize := length / time.Duration(n)
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &window{
+		buckets:    make([]bucket, n),
+		bucketSize: bucketSize,
+		length:     bucketSize * time.Duration(n),
+	}
+}
+
+// record 在当前时间片记录一次请求
+func (w *window) record(now time.Time, failed bool) {
+	start := now.Truncate(w.bucketSize)
+	index := (start.UnixNano() / int64(w.bucketSize)) % int64(len(w.buckets))
+	if index < 0 {
+		index += int64(len(w.buckets))
+	}
+	b := &w.buckets[index]
+
+	// 桶属于更早的时间片，重新使用
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+}
+
+// totals 返回窗口内的请求数和失败数
+func (w *window) totals(now time.Time) (requests, failures int) {
+	for _, b := range w.buckets {
+		if b.requests == 0 || now.Sub(b.start) >= w.length {
+			continue
+		}
+		requests += b.requests
+		failures += b.failures
+	}
+	return requests, failures
+}
+
+// reset 清空窗口
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
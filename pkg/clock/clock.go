@@ -0,0 +1,110 @@
+package clock
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock 抽象了时间相关的操作，便于在测试中替换为可控的时钟
+type Clock interface {
+	// Now 返回当前时间
+	Now() time.Time
+	// Since 返回自t以来经过的时间
+	Since(t time.Time) time.Duration
+	// NewTimer 创建一个在d之后触发的定时器
+	NewTimer(d time.Duration) Timer
+	// NewTicker 创建一个周期为d的打点器
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 是 time.Timer 的抽象
+type Timer interface {
+	// C 返回定时器触发时写入的通道
+	C() <-chan time.Time
+	// Stop 停止定时器，返回定时器是否处于活动状态
+	Stop() bool
+	// Reset 重置定时器在d之后触发
+	Reset(d time.Duration) bool
+}
+
+// Ticker 是 time.Ticker 的抽象
+type Ticker interface {
+	// C 返回每次打点时写入的通道
+	C() <-chan time.Time
+	// Stop 停止打点器
+	Stop()
+}
+
+// Rand 是随机数来源的抽象，*rand.Rand 满足该接口
+//
+// 注意 *rand.Rand 不是并发安全的，并发使用时需自行加锁
+type Rand interface {
+	// Float64 返回 [0.0, 1.0) 区间内的伪随机数
+	Float64() float64
+}
+
+// Real 返回使用系统时间的时钟
+func Real() Clock {
+	return realClock{}
+}
+
+// GlobalRand 返回使用 math/rand 全局随机源的 Rand
+func GlobalRand() Rand {
+	return globalRand{}
+}
+
+// realClock 基于 time 包实现 Clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+// realTimer 包装 time.Timer
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+// realTicker 包装 time.Ticker
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// globalRand 使用 math/rand 的全局随机源，并发安全
+type globalRand struct{}
+
+func (globalRand) Float64() float64 {
+	return rand.Float64()
+}
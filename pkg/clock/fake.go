@@ -0,0 +1,190 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake 是一个只在手动推进时才前进的时钟，用于测试
+//
+// 定时器和打点器在 Advance 推进到其触发时间时按时间顺序触发，
+// 触发时间写入容量为1的通道，接收方来不及读取时丢弃（与 time.Ticker 行为一致）
+type Fake struct {
+	now     time.Time
+	waiters []*fakeWaiter
+	mutex   sync.Mutex
+	cond    *sync.Cond
+}
+
+// NewFake 创建一个从 start 开始的假时钟
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+// Now 返回假时钟的当前时间
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Since 返回自t以来在假时钟上经过的时间
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// NewTimer 创建一个在假时钟上d之后触发的定时器
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.addWaiter(d, 0)
+}
+
+// NewTicker 创建一个在假时钟上周期为d的打点器
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{waiter: f.addWaiter(d, d)}
+}
+
+// Advance 将时钟推进d，并按顺序触发期间到期的定时器和打点器
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		next := f.nextWaiter(target)
+		if next == nil {
+			break
+		}
+
+		f.now = next.deadline
+		next.fire()
+	}
+	f.now = target
+}
+
+// BlockUntil 阻塞直到假时钟上至少有n个活动的定时器或打点器
+//
+// 用于确保被测试的goroutine已经开始等待后再调用 Advance
+func (f *Fake) BlockUntil(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// addWaiter 注册一个新的定时器或打点器
+func (f *Fake) addWaiter(d, period time.Duration) *fakeWaiter {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w := &fakeWaiter{
+		fake:     f,
+		c:        make(chan time.Time, 1),
+		deadline: f.now.Add(d),
+		period:   period,
+		active:   true,
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+
+	// 非正数的定时器立即触发
+	if period == 0 && d <= 0 {
+		w.fire()
+	}
+	return w
+}
+
+// nextWaiter 返回在 target 之前最早到期的活动等待者，调用方需持有锁
+func (f *Fake) nextWaiter(target time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, w := range f.waiters {
+		if !w.active || w.deadline.After(target) {
+			continue
+		}
+		if next == nil || w.deadline.Before(next.deadline) {
+			next = w
+		}
+	}
+	return next
+}
+
+// removeWaiter 移除一个等待者，调用方需持有锁
+func (f *Fake) removeWaiter(target *fakeWaiter) {
+	for i, w := range f.waiters {
+		if w == target {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// fakeWaiter 是假时钟上的定时器，也是打点器的底层实现
+type fakeWaiter struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration
+	active   bool
+}
+
+// fire 触发等待者，调用方需持有锁
+func (w *fakeWaiter) fire() {
+	select {
+	case w.c <- w.deadline:
+	default:
+	}
+
+	if w.period > 0 {
+		w.deadline = w.deadline.Add(w.period)
+	} else {
+		w.active = false
+		w.fake.removeWaiter(w)
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.fake.mutex.Lock()
+	defer w.fake.mutex.Unlock()
+
+	wasActive := w.active
+	w.active = false
+	w.fake.removeWaiter(w)
+	return wasActive
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.fake.mutex.Lock()
+	defer w.fake.mutex.Unlock()
+
+	wasActive := w.active
+	w.deadline = w.fake.now.Add(d)
+	if !wasActive {
+		w.active = true
+		w.fake.waiters = append(w.fake.waiters, w)
+		w.fake.cond.Broadcast()
+	}
+	return wasActive
+}
+
+// fakeTicker 是假时钟上的打点器
+type fakeTicker struct {
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.waiter.Stop()
+}
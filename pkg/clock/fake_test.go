@@ -0,0 +1,74 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimerFiresOnAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	fake := NewFake(start)
+	timer := fake.NewTimer(time.Second)
+
+	fake.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	fake.Advance(time.Millisecond)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(start.Add(time.Second)) {
+			t.Errorf("fired at %v, want %v", at, start.Add(time.Second))
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+}
+
+func TestFakeTickerDropsMissedTicks(t *testing.T) {
+	fake := NewFake(time.Unix(0, 0))
+	ticker := fake.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// 与 time.Ticker 一样，接收方来不及读取时只保留一次打点
+	fake.Advance(3 * time.Second)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("missed ticks were buffered")
+	default:
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("ticker did not fire after advance")
+	}
+}
+
+func TestFakeTimerStopAndReset(t *testing.T) {
+	fake := NewFake(time.Unix(0, 0))
+	timer := fake.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Fatal("Stop on an active timer returned false")
+	}
+	fake.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	timer.Reset(time.Second)
+	fake.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+}
@@ -0,0 +1,141 @@
+package healthcheck
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// DampingConfig 定义健康状态切换的抖动抑制配置
+type DampingConfig struct {
+	RiseThreshold int           // 连续多少次非DOWN结果后才恢复，小于1时按1处理
+	FallThreshold int           // 连续多少次DOWN结果后才判定为DOWN，小于1时按1处理
+	MinHoldTime   time.Duration // 状态切换后至少保持的时间，0表示不限制
+	HistorySize   int           // 保留的状态切换记录数，0表示不保留
+}
+
+// DefaultDampingConfig 返回默认的抖动抑制配置
+func DefaultDampingConfig() DampingConfig {
+	return DampingConfig{
+		RiseThreshold: 2,
+		FallThreshold: 3,
+		MinHoldTime:   10 * time.Second,
+		HistorySize:   50,
+	}
+}
+
+// Transition 表示一次状态切换
+type Transition struct {
+	Name   string    `json:"name"`
+	From   Status    `json:"from"`
+	To     Status    `json:"to"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Damper 对观察到的健康状态做抖动抑制
+//
+// 只有连续 FallThreshold 次观察到DOWN才切换为DOWN，连续 RiseThreshold 次观察到
+// 同一个非DOWN状态才切换为该状态，且距上次切换不少于 MinHoldTime
+type Damper struct {
+	name    string
+	config  DampingConfig
+	clock   clock.Clock
+	state   Status
+	since   time.Time
+	pending Status
+	streak  int
+	history []Transition
+	mutex   sync.Mutex
+}
+
+// NewDamper 创建抖动抑制器，initial 为空时以第一次观察到的状态作为初始状态
+func NewDamper(name string, initial Status, config DampingConfig, clk clock.Clock) *Damper {
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &Damper{
+		name:   name,
+		config: config,
+		clock:  clk,
+		state:  initial,
+		since:  clk.Now(),
+	}
+}
+
+// Observe 记录一次观察结果，返回抑制后的状态以及本次是否发生了切换
+func (d *Damper) Observe(status Status, reason string) (Status, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.clock.Now()
+	if d.state == "" {
+		d.state = status
+		d.since = now
+		return d.state, false
+	}
+
+	if status == d.state {
+		d.pending = ""
+		d.streak = 0
+		return d.state, false
+	}
+
+	if status == d.pending {
+		d.streak++
+	} else {
+		d.pending = status
+		d.streak = 1
+	}
+
+	threshold := d.config.RiseThreshold
+	if status == StatusDown {
+		threshold = d.config.FallThreshold
+	}
+	if d.streak < threshold || now.Sub(d.since) < d.config.MinHoldTime {
+		return d.state, false
+	}
+
+	d.record(Transition{Name: d.name, From: d.state, To: status, At: now, Reason: reason})
+	d.state = status
+	d.since = now
+	d.pending = ""
+	d.streak = 0
+	return d.state, true
+}
+
+// State 返回当前抑制后的状态
+func (d *Damper) State() Status {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.state
+}
+
+// Pending 返回是否有尚未达到阈值的候选状态
+func (d *Damper) Pending() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.pending != ""
+}
+
+// History 返回状态切换记录，按时间从早到晚排列
+func (d *Damper) History() []Transition {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	history := make([]Transition, len(d.history))
+	copy(history, d.history)
+	return history
+}
+
+// record 追加一条切换记录，超过 HistorySize 时丢弃最早的记录
+func (d *Damper) record(t Transition) {
+	if d.config.HistorySize <= 0 {
+		return
+	}
+	d.history = append(d.history, t)
+	if len(d.history) > d.config.HistorySize {
+		d.history = d.history[len(d.history)-d.config.HistorySize:]
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
 )
 
 // Status 表示健康检查状态
@@ -16,6 +20,27 @@ const (
 	StatusUp Status = "UP"
 	// StatusDown 表示检查失败
 	StatusDown Status = "DOWN"
+	// StatusDegraded 表示部分非关键功能不可用，但仍可以提供服务
+	StatusDegraded Status = "DEGRADED"
+)
+
+// DefaultStatusCodes 返回默认的状态到HTTP状态码的映射，降级时仍返回200以免被摘除流量
+func DefaultStatusCodes() map[Status]int {
+	return map[Status]int{
+		StatusUp:       http.StatusOK,
+		StatusDegraded: http.StatusOK,
+		StatusDown:     http.StatusServiceUnavailable,
+	}
+}
+
+// Criticality 表示检查的重要程度
+type Criticality int
+
+const (
+	// Critical 关键检查，失败时总体状态为DOWN
+	Critical Criticality = iota
+	// NonCritical 非关键检查，失败时总体状态为DEGRADED
+	NonCritical
 )
 
 // Check 是一个健康检查接口
@@ -28,9 +53,14 @@ type Check interface {
 
 // Result 表示单个健康检查的结果
 type Result struct {
-	Name   string `json:"name"`
-	Status Status `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Name                string    `json:"name"`
+	Status              Status    `json:"status"`
+	ObservedStatus      Status    `json:"observed_status"` // 本次检查的原始状态，Status 为抖动抑制后的状态
+	Error               string    `json:"error,omitempty"`
+	Critical            bool      `json:"critical"`
+	LastChecked         time.Time `json:"last_checked"`
+	Duration            string    `json:"duration"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
 }
 
 // AggregateResult 表示所有健康检查的聚合结果
@@ -39,64 +69,349 @@ type AggregateResult struct {
 	Details map[string]Result `json:"details"`
 }
 
+// ErrCheckTimeout 表示检查没有在超时时间内完成
+var ErrCheckTimeout = errors.New("health check timed out")
+
+// TimeoutCheck 由需要单独超时时间的检查实现，未实现时使用 Checker 的默认超时
+type TimeoutCheck interface {
+	Timeout() time.Duration
+}
+
+// instantCheck 由执行开销可以忽略的检查实现，后台模式下每次查询时仍然实时执行
+type instantCheck interface {
+	instant()
+}
+
+// registeredCheck 是注册到 Checker 的检查及其重要程度
+type registeredCheck struct {
+	check       Check
+	criticality Criticality
+}
+
+// checkState 保存单个检查的历史状态
+type checkState struct {
+	consecutiveFailures int
+	damper              *Damper
+}
+
 // Checker 是健康检查管理器
+//
+// RunChecks 并发执行所有检查，每个检查有独立的超时时间。调用 Start 后进入
+// 后台模式，按固定间隔执行检查，Results 直接返回缓存的结果。每个检查的状态
+// 经过抖动抑制，手动切换的检查（如 ToggleCheck）不做抑制
 type Checker struct {
-	checks []Check
-	mutex  sync.RWMutex
+	checks  []registeredCheck
+	timeout time.Duration
+	clock   clock.Clock
+	damping DampingConfig
+	mutex   sync.RWMutex
+
+	states     map[string]*checkState
+	cached     *AggregateResult
+	running    bool
+	stateMutex sync.Mutex
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+}
+
+// CheckerOption 用于配置 Checker 的可选项
+type CheckerOption func(*Checker)
+
+// WithCheckTimeout 设置每个检查的默认超时时间
+func WithCheckTimeout(timeout time.Duration) CheckerOption {
+	return func(c *Checker) {
+		c.timeout = timeout
+	}
+}
+
+// WithCheckerClock 设置后台检查和结果时间戳使用的时钟
+func WithCheckerClock(clk clock.Clock) CheckerOption {
+	return func(c *Checker) {
+		c.clock = clk
+	}
+}
+
+// WithDamping 设置检查状态的抖动抑制，默认每次检查结果立即生效
+func WithDamping(config DampingConfig) CheckerOption {
+	return func(c *Checker) {
+		c.damping = config
+	}
 }
 
 // NewChecker 创建新的健康检查管理器
-func NewChecker() *Checker {
-	return &Checker{
-		checks: []Check{},
+func NewChecker(opts ...CheckerOption) *Checker {
+	c := &Checker{
+		checks:   []registeredCheck{},
+		timeout:  5 * time.Second,
+		clock:    clock.Real(),
+		damping:  DampingConfig{HistorySize: DefaultDampingConfig().HistorySize},
+		states:   make(map[string]*checkState),
+		stopChan: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-// AddCheck 添加一个健康检查
+// AddCheck 添加一个关键健康检查
 func (c *Checker) AddCheck(check Check) {
+	c.AddCheckWithCriticality(check, Critical)
+}
+
+// AddCheckWithCriticality 添加一个指定重要程度的健康检查
+func (c *Checker) AddCheckWithCriticality(check Check, criticality Criticality) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.checks = append(c.checks, check)
+	c.checks = append(c.checks, registeredCheck{check: check, criticality: criticality})
 }
 
-// RunChecks 执行所有健康检查
-func (c *Checker) RunChecks(ctx context.Context) AggregateResult {
+// snapshot 复制当前的检查列表
+func (c *Checker) snapshot() []registeredCheck {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	aggregateResult := AggregateResult{
-		Status:  StatusUp,
-		Details: make(map[string]Result),
+	checks := make([]registeredCheck, len(c.checks))
+	copy(checks, c.checks)
+	return checks
+}
+
+// RunChecks 并发执行所有健康检查，并更新缓存的结果
+func (c *Checker) RunChecks(ctx context.Context) AggregateResult {
+	// 只在复制检查列表时持有锁，避免慢检查阻塞 AddCheck
+	checks := c.snapshot()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, rc := range checks {
+		wg.Add(1)
+		go func(i int, rc registeredCheck) {
+			defer wg.Done()
+			results[i] = c.execute(ctx, rc)
+		}(i, rc)
 	}
+	wg.Wait()
 
-	// 如果没有配置检查项，返回UP状态
-	if len(c.checks) == 0 {
-		return aggregateResult
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	details := make(map[string]Result, len(results))
+	for i, result := range results {
+		state, ok := c.states[result.Name]
+		if !ok {
+			state = &checkState{damper: c.newDamper(checks[i])}
+			c.states[result.Name] = state
+		}
+		if result.Status == StatusDown {
+			state.consecutiveFailures++
+		} else {
+			state.consecutiveFailures = 0
+		}
+		result.ConsecutiveFailures = state.consecutiveFailures
+		result.Status, _ = state.damper.Observe(result.ObservedStatus, result.Error)
+		details[result.Name] = result
+	}
+
+	aggregateResult := aggregate(details)
+	c.cached = &aggregateResult
+	return copyResult(aggregateResult)
+}
+
+// newDamper 为检查创建抖动抑制器，手动切换的检查只记录切换历史
+func (c *Checker) newDamper(rc registeredCheck) *Damper {
+	config := c.damping
+	if _, ok := rc.check.(instantCheck); ok {
+		config = DampingConfig{HistorySize: c.damping.HistorySize}
 	}
+	return NewDamper(rc.check.Name(), "", config, c.clock)
+}
 
-	// 执行所有检查
-	for _, check := range c.checks {
-		status, err := check.Execute(ctx)
-		result := Result{
-			Name:   check.Name(),
-			Status: status,
+// History 返回所有检查的状态切换记录，按时间从早到晚排列
+func (c *Checker) History() []Transition {
+	c.stateMutex.Lock()
+	var history []Transition
+	for _, state := range c.states {
+		history = append(history, state.damper.History()...)
+	}
+	c.stateMutex.Unlock()
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].At.Before(history[j].At)
+	})
+	if size := c.damping.HistorySize; size > 0 && len(history) > size {
+		history = history[len(history)-size:]
+	}
+	return history
+}
+
+// Results 返回健康检查结果
+//
+// 后台模式下返回最近一次的缓存结果，其中开销可以忽略的检查（如 ToggleCheck）
+// 实时执行；非后台模式或尚无缓存时同步执行所有检查
+func (c *Checker) Results(ctx context.Context) AggregateResult {
+	c.stateMutex.Lock()
+	running := c.running
+	cached := c.cached
+	var result AggregateResult
+	if cached != nil {
+		result = copyResult(*cached)
+	}
+	c.stateMutex.Unlock()
+
+	if !running || cached == nil {
+		return c.RunChecks(ctx)
+	}
+
+	refreshed := false
+	for _, rc := range c.snapshot() {
+		if _, ok := rc.check.(instantCheck); !ok {
+			continue
+		}
+		current := c.execute(ctx, rc)
+		current.ConsecutiveFailures = result.Details[current.Name].ConsecutiveFailures
+		if current.Status == StatusDown && current.ConsecutiveFailures == 0 {
+			current.ConsecutiveFailures = 1
 		}
+		result.Details[current.Name] = current
+		refreshed = true
+	}
+	if refreshed {
+		result.Status = aggregate(result.Details).Status
+	}
 
-		if err != nil {
-			result.Error = err.Error()
+	return result
+}
+
+// Start 进入后台模式，立即执行一次检查，之后每隔 interval 执行一次
+func (c *Checker) Start(interval time.Duration) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if c.running || interval <= 0 {
+		return
+	}
+	c.running = true
+
+	ticker := c.clock.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+
+		// 停止时取消正在执行的检查
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-c.stopChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for {
+			c.RunChecks(ctx)
+
+			select {
+			case <-ticker.C():
+			case <-c.stopChan:
+				return
+			}
 		}
+	}()
+}
 
-		aggregateResult.Details[check.Name()] = result
+// Stop 停止后台检查，之后 Results 同步执行检查
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		c.stateMutex.Lock()
+		c.running = false
+		c.stateMutex.Unlock()
+		close(c.stopChan)
+	})
+}
 
-		// 如果任何一个检查失败，设置总体状态为DOWN
-		if status == StatusDown {
-			aggregateResult.Status = StatusDown
+// execute 在独立的超时时间内执行单个检查，检查不响应上下文取消时也会按时返回
+func (c *Checker) execute(ctx context.Context, rc registeredCheck) Result {
+	check := rc.check
+	timeout := c.timeout
+	if tc, ok := check.(TimeoutCheck); ok && tc.Timeout() > 0 {
+		timeout = tc.Timeout()
+	}
+
+	checkCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		status Status
+		err    error
+	}
+	done := make(chan outcome, 1)
+
+	start := c.clock.Now()
+	go func() {
+		status, err := check.Execute(checkCtx)
+		done <- outcome{status: status, err: err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-checkCtx.Done():
+		o = outcome{status: StatusDown, err: ErrCheckTimeout}
+		if ctx.Err() != nil {
+			o.err = ctx.Err()
 		}
 	}
 
+	result := Result{
+		Name:           check.Name(),
+		Status:         o.status,
+		ObservedStatus: o.status,
+		Critical:       rc.criticality == Critical,
+		LastChecked:    start,
+		Duration:       c.clock.Since(start).String(),
+	}
+	if o.err != nil {
+		result.Error = o.err.Error()
+	}
+	return result
+}
+
+// aggregate 根据各检查的结果计算总体状态
+//
+// 关键检查失败时为DOWN；非关键检查失败或任何检查报告降级时为DEGRADED
+func aggregate(details map[string]Result) AggregateResult {
+	aggregateResult := AggregateResult{
+		Status:  StatusUp,
+		Details: details,
+	}
+	for _, result := range details {
+		switch {
+		case result.Status == StatusDown && result.Critical:
+			aggregateResult.Status = StatusDown
+		case result.Status == StatusDown || result.Status == StatusDegraded:
+			if aggregateResult.Status != StatusDown {
+				aggregateResult.Status = StatusDegraded
+			}
+		}
+	}
 	return aggregateResult
 }
 
+// copyResult 复制聚合结果，避免调用方修改缓存
+func copyResult(result AggregateResult) AggregateResult {
+	details := make(map[string]Result, len(result.Details))
+	for name, r := range result.Details {
+		details[name] = r
+	}
+	return AggregateResult{Status: result.Status, Details: details}
+}
+
 // HTTPCheck 实现了对HTTP服务的健康检查
 type HTTPCheck struct {
 	name    string
@@ -122,6 +437,11 @@ func (h *HTTPCheck) Name() string {
 	return h.name
 }
 
+// Timeout 返回检查的超时时间
+func (h *HTTPCheck) Timeout() time.Duration {
+	return h.timeout
+}
+
 // Execute 执行HTTP健康检查
 func (h *HTTPCheck) Execute(ctx context.Context) (Status, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", h.url, nil)
@@ -165,3 +485,102 @@ func (c *CustomCheck) Name() string {
 func (c *CustomCheck) Execute(ctx context.Context) (Status, error) {
 	return c.fn(ctx)
 }
+
+// ToggleCheck 是由程序手动切换状态的检查，如启动完成或开始优雅关闭
+type ToggleCheck struct {
+	name   string
+	up     bool
+	reason string
+	mutex  sync.RWMutex
+}
+
+// NewToggleCheck 创建一个手动切换状态的检查
+func NewToggleCheck(name string, up bool) *ToggleCheck {
+	return &ToggleCheck{
+		name: name,
+		up:   up,
+	}
+}
+
+// Name 返回检查名称
+func (t *ToggleCheck) Name() string {
+	return t.name
+}
+
+// SetUp 将检查状态设为UP
+func (t *ToggleCheck) SetUp() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.up = true
+	t.reason = ""
+}
+
+// SetDown 将检查状态设为DOWN，reason 作为检查的错误信息返回
+func (t *ToggleCheck) SetDown(reason string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.up = false
+	t.reason = reason
+}
+
+// instant 标记状态切换后立即生效，后台模式下不使用缓存
+func (t *ToggleCheck) instant() {}
+
+// Execute 返回当前状态
+func (t *ToggleCheck) Execute(ctx context.Context) (Status, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if t.up {
+		return StatusUp, nil
+	}
+	if t.reason != "" {
+		return StatusDown, errors.New(t.reason)
+	}
+	return StatusDown, nil
+}
+
+// HedgedCheck 使用对冲请求执行另一个检查，降低偶发的慢响应对健康检查的影响
+type HedgedCheck struct {
+	check  Check
+	config *retry.HedgeConfig
+}
+
+// NewHedgedCheck 创建一个对冲健康检查，被包装的检查必须是幂等的
+func NewHedgedCheck(check Check, config *retry.HedgeConfig) *HedgedCheck {
+	return &HedgedCheck{
+		check:  check,
+		config: config,
+	}
+}
+
+// Name 返回被包装检查的名称
+func (h *HedgedCheck) Name() string {
+	return h.check.Name()
+}
+
+// Timeout 返回被包装检查的超时时间
+func (h *HedgedCheck) Timeout() time.Duration {
+	if tc, ok := h.check.(TimeoutCheck); ok {
+		return tc.Timeout()
+	}
+	return 0
+}
+
+// Execute 以对冲方式执行检查
+//
+// 只有返回错误或DOWN的请求视为失败，使用第一个非DOWN的结果；
+// DEGRADED 等状态原样返回，不会被当作失败而变成DOWN
+func (h *HedgedCheck) Execute(ctx context.Context) (Status, error) {
+	status, err := retry.Hedge(ctx, func(ctx context.Context) (Status, error) {
+		status, err := h.check.Execute(ctx)
+		if err == nil && status == StatusDown {
+			err = errors.New("check status: " + string(status))
+		}
+		return status, err
+	}, h.config)
+	if err != nil {
+		return StatusDown, err
+	}
+	return status, nil
+}
@@ -0,0 +1,288 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/retry"
+)
+
+func TestHedgedCheckStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  Status
+		err     error
+		want    Status
+		wantErr bool
+	}{
+		{"up", StatusUp, nil, StatusUp, false},
+		{"degraded passes through", StatusDegraded, nil, StatusDegraded, false},
+		{"down", StatusDown, nil, StatusDown, true},
+		{"error", StatusUp, errors.New("connection refused"), StatusDown, true},
+		{"degraded with error", StatusDegraded, errors.New("timeout"), StatusDown, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := NewHedgedCheck(NewCustomCheck("dependency", func(ctx context.Context) (Status, error) {
+				return tt.status, tt.err
+			}), &retry.HedgeConfig{Delay: time.Hour, MaxHedges: 1})
+
+			status, err := check.Execute(context.Background())
+			if status != tt.want {
+				t.Errorf("status %s, want %s", status, tt.want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckerKeepsHedgedDegraded(t *testing.T) {
+	checker := NewChecker()
+	checker.AddCheckWithCriticality(NewHedgedCheck(NewCustomCheck("notification", func(ctx context.Context) (Status, error) {
+		return StatusDegraded, nil
+	}), &retry.HedgeConfig{Delay: time.Hour, MaxHedges: 1}), Critical)
+
+	result := checker.RunChecks(context.Background())
+	if result.Status != StatusDegraded {
+		t.Fatalf("aggregate status %s, want DEGRADED", result.Status)
+	}
+	if detail := result.Details["notification"]; detail.Status != StatusDegraded || detail.Error != "" {
+		t.Fatalf("got %+v, want DEGRADED without error", detail)
+	}
+}
+
+// slowCheck 阻塞到 release 关闭，不响应上下文取消
+type slowCheck struct {
+	name    string
+	timeout time.Duration
+	release chan struct{}
+}
+
+func (s *slowCheck) Name() string { return s.name }
+
+func (s *slowCheck) Timeout() time.Duration { return s.timeout }
+
+func (s *slowCheck) Execute(context.Context) (Status, error) {
+	<-s.release
+	return StatusUp, nil
+}
+
+// sequenceCheck 依次返回 statuses 中的状态，并记录执行次数
+type sequenceCheck struct {
+	name     string
+	statuses []Status
+	calls    atomic.Int32
+}
+
+func (s *sequenceCheck) Name() string { return s.name }
+
+func (s *sequenceCheck) Execute(context.Context) (Status, error) {
+	i := int(s.calls.Add(1)) - 1
+	if i >= len(s.statuses) {
+		i = len(s.statuses) - 1
+	}
+	if s.statuses[i] == StatusDown {
+		return StatusDown, errors.New("unavailable")
+	}
+	return s.statuses[i], nil
+}
+
+// eventually 等待条件成立，超过1秒时失败
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCheckerRunsChecksConcurrently(t *testing.T) {
+	const checks = 3
+	var started atomic.Int32
+	allStarted := make(chan struct{})
+
+	checker := NewChecker(WithCheckTimeout(time.Second))
+	for i := 0; i < checks; i++ {
+		checker.AddCheck(NewCustomCheck(fmt.Sprintf("check-%d", i), func(ctx context.Context) (Status, error) {
+			// 每个检查都等到所有检查开始执行，顺序执行时会超时
+			if started.Add(1) == checks {
+				close(allStarted)
+			}
+			select {
+			case <-allStarted:
+				return StatusUp, nil
+			case <-ctx.Done():
+				return StatusDown, ctx.Err()
+			}
+		}))
+	}
+
+	result := checker.RunChecks(context.Background())
+	if result.Status != StatusUp {
+		t.Fatalf("got %+v, want all checks UP when run concurrently", result)
+	}
+}
+
+func TestCheckerTimeouts(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultTimeout time.Duration
+		checkTimeout   time.Duration
+		want           Status
+	}{
+		{"default timeout", 20 * time.Millisecond, 0, StatusDown},
+		{"per-check timeout overrides default", time.Hour, 20 * time.Millisecond, StatusDown},
+		{"per-check timeout longer than default", 20 * time.Millisecond, time.Hour, StatusUp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := &slowCheck{name: "slow", timeout: tt.checkTimeout, release: make(chan struct{})}
+			checker := NewChecker(WithCheckTimeout(tt.defaultTimeout))
+			checker.AddCheck(check)
+
+			// 检查不响应取消时也要按时返回，不会超时的检查在稍后放行
+			if tt.want == StatusUp {
+				time.AfterFunc(50*time.Millisecond, func() { close(check.release) })
+			} else {
+				defer close(check.release)
+			}
+
+			start := time.Now()
+			detail := checker.RunChecks(context.Background()).Details["slow"]
+			if detail.Status != tt.want {
+				t.Fatalf("status %s, want %s", detail.Status, tt.want)
+			}
+			if tt.want == StatusDown {
+				if detail.Error != ErrCheckTimeout.Error() {
+					t.Errorf("error %q, want %q", detail.Error, ErrCheckTimeout)
+				}
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("timed out check took %v", elapsed)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerConsecutiveFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []Status
+		want     []int
+	}{
+		{"always up", []Status{StatusUp, StatusUp}, []int{0, 0}},
+		{"failures accumulate", []Status{StatusDown, StatusDown, StatusDown}, []int{1, 2, 3}},
+		{"success resets", []Status{StatusDown, StatusDown, StatusUp, StatusDown}, []int{1, 2, 0, 1}},
+		{"degraded resets", []Status{StatusDown, StatusDegraded, StatusDown}, []int{1, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			checker.AddCheck(&sequenceCheck{name: "dependency", statuses: tt.statuses})
+
+			for i, want := range tt.want {
+				detail := checker.RunChecks(context.Background()).Details["dependency"]
+				if detail.ConsecutiveFailures != want {
+					t.Fatalf("run %d: %d consecutive failures, want %d", i, detail.ConsecutiveFailures, want)
+				}
+				if detail.Status != tt.statuses[i] {
+					t.Fatalf("run %d: status %s, want %s", i, detail.Status, tt.statuses[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerBackgroundCachesResults(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	check := &sequenceCheck{name: "dependency", statuses: []Status{StatusUp, StatusDown}}
+	checker := NewChecker(WithCheckerClock(fake))
+	checker.AddCheck(check)
+
+	checker.Start(10 * time.Second)
+	defer checker.Stop()
+
+	// Start 立即执行一次检查
+	eventually(t, func() bool { return check.calls.Load() == 1 })
+	eventually(t, func() bool {
+		checker.stateMutex.Lock()
+		defer checker.stateMutex.Unlock()
+		return checker.cached != nil
+	})
+
+	// 间隔内查询返回缓存的结果，不执行检查
+	for i := 0; i < 3; i++ {
+		fake.Advance(3 * time.Second)
+		if result := checker.Results(context.Background()); result.Status != StatusUp {
+			t.Fatalf("cached status %s, want UP", result.Status)
+		}
+	}
+	if calls := check.calls.Load(); calls != 1 {
+		t.Fatalf("check ran %d times within the interval, want 1", calls)
+	}
+
+	// 到达间隔后后台刷新缓存
+	fake.Advance(time.Second)
+	eventually(t, func() bool { return checker.Results(context.Background()).Status == StatusDown })
+	if result := checker.Results(context.Background()); !result.Details["dependency"].LastChecked.Equal(time.Unix(10, 0)) {
+		t.Fatalf("last checked %v, want the refresh at 10s", result.Details["dependency"].LastChecked)
+	}
+
+	// 停止后同步执行检查
+	checker.Stop()
+	checker.Results(context.Background())
+	if calls := check.calls.Load(); calls != 3 {
+		t.Fatalf("check ran %d times after Stop, want 3", calls)
+	}
+}
+
+func TestCheckerBackgroundEvaluatesInstantChecksLive(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	toggle := NewToggleCheck("shutdown", true)
+	checker := NewChecker(WithCheckerClock(fake))
+	checker.AddCheck(toggle)
+	checker.AddCheck(NewCustomCheck("dependency", func(context.Context) (Status, error) {
+		return StatusUp, nil
+	}))
+
+	checker.Start(time.Hour)
+	defer checker.Stop()
+	eventually(t, func() bool {
+		checker.stateMutex.Lock()
+		defer checker.stateMutex.Unlock()
+		return checker.cached != nil
+	})
+
+	// 手动切换的检查不等待下一次后台检查
+	toggle.SetDown("shutting down")
+	result := checker.Results(context.Background())
+	if result.Status != StatusDown {
+		t.Fatalf("status %s right after SetDown, want DOWN", result.Status)
+	}
+	detail := result.Details["shutdown"]
+	if detail.Error != "shutting down" || detail.ConsecutiveFailures != 1 {
+		t.Fatalf("got %+v, want the shutdown reason with one failure", detail)
+	}
+	if result.Details["dependency"].Status != StatusUp {
+		t.Fatal("cached dependency result lost")
+	}
+
+	toggle.SetUp()
+	if status := checker.Results(context.Background()).Status; status != StatusUp {
+		t.Fatalf("status %s after SetUp, want UP", status)
+	}
+}
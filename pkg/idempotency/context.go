@@ -0,0 +1,19 @@
+package idempotency
+
+import "context"
+
+// contextKey 是幂等键在 context.Context 中的键类型
+type contextKey struct{}
+
+// NewContext 返回携带幂等键的上下文
+func NewContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext 返回上下文中的幂等键，不存在时返回空字符串
+//
+// 只有幂等键中间件接受并开始处理的请求才带有幂等键，处理函数可以据此对副作用去重
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(contextKey{}).(string)
+	return key
+}
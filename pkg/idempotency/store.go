@@ -0,0 +1,215 @@
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var (
+	// ErrFingerprintMismatch 表示幂等键被用于内容不同的请求
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+)
+
+// Response 是为幂等键保存的最终响应
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// entry 是一个幂等键的状态
+type entry struct {
+	fingerprint string
+	response    *Response     // 为nil表示第一个请求仍在处理中
+	done        chan struct{} // 第一个请求结束时关闭
+	expiresAt   time.Time
+	element     *list.Element // 在LRU链表中的位置，处理中的键为nil
+}
+
+// DefaultMaxEntries 是 MemoryStore 默认保存的最大键数
+const DefaultMaxEntries = 100000
+
+// MemoryStore 是基于内存的幂等键存储
+//
+// 已完成的键按最近使用顺序保存，键数达到上限时淘汰最久未使用的键，
+// 防止客户端发送大量不同的幂等键耗尽内存；处理中的键不会被淘汰
+type MemoryStore struct {
+	ttl        time.Duration
+	maxEntries int
+	clock      clock.Clock
+	entries    map[string]*entry
+	lru        *list.List // 已完成的键，表头为最近使用
+	lastSweep  time.Time
+	mutex      sync.Mutex
+}
+
+// MemoryStoreOption 用于配置 MemoryStore 的可选项
+type MemoryStoreOption func(*MemoryStore)
+
+// WithMaxEntries 设置保存的最大键数，为0时不限制
+func WithMaxEntries(n int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.maxEntries = n
+	}
+}
+
+// NewMemoryStore 创建新的内存幂等键存储，键在 ttl 后过期
+func NewMemoryStore(ttl time.Duration, clk clock.Clock, opts ...MemoryStoreOption) *MemoryStore {
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	s := &MemoryStore{
+		ttl:        ttl,
+		maxEntries: DefaultMaxEntries,
+		clock:      clk,
+		entries:    make(map[string]*entry),
+		lru:        list.New(),
+		lastSweep:  clk.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Len 返回当前保存的键数，包括处理中的键
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+// Fingerprint 计算请求的指纹，用于识别同一幂等键下内容不同的请求
+func Fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Begin 开始处理一个幂等键
+//
+// 第一次出现的键返回 (nil, nil)，调用方处理请求后必须调用 Complete 或 Abort；
+// 已完成的键返回保存的响应；键仍在处理中时等待其完成；
+// 指纹不一致时返回 ErrFingerprintMismatch
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	for {
+		s.mutex.Lock()
+		now := s.clock.Now()
+		s.sweep(now)
+
+		e, ok := s.entries[key]
+		// 处理中的键不会过期，避免与仍在执行的请求重复执行
+		if !ok || (e.response != nil && !now.Before(e.expiresAt)) {
+			if ok {
+				s.remove(key, e)
+			}
+			s.evict()
+			s.entries[key] = &entry{
+				fingerprint: fingerprint,
+				done:        make(chan struct{}),
+				expiresAt:   now.Add(s.ttl),
+			}
+			s.mutex.Unlock()
+			return nil, nil
+		}
+
+		if e.fingerprint != fingerprint {
+			s.mutex.Unlock()
+			return nil, ErrFingerprintMismatch
+		}
+		if e.response != nil {
+			response := e.response
+			s.lru.MoveToFront(e.element)
+			s.mutex.Unlock()
+			return response, nil
+		}
+
+		// 等待第一个请求完成后重新检查
+		done := e.done
+		s.mutex.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Complete 保存键的最终响应并唤醒等待的重复请求
+func (s *MemoryStore) Complete(key string, response *Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return
+	}
+
+	e.response = response
+	e.expiresAt = s.clock.Now().Add(s.ttl)
+	e.element = s.lru.PushFront(key)
+	close(e.done)
+}
+
+// Abort 放弃处理中的键，等待中的重复请求将重新执行
+func (s *MemoryStore) Abort(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || e.response != nil {
+		return
+	}
+
+	delete(s.entries, key)
+	close(e.done)
+}
+
+// sweep 定期清理过期的键，调用方需持有锁
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, e := range s.entries {
+		// 处理中的键由 Complete 或 Abort 负责清理
+		if e.response != nil && !now.Before(e.expiresAt) {
+			s.remove(key, e)
+		}
+	}
+}
+
+// evict 在键数达到上限时淘汰最久未使用的已完成键，为新键腾出位置，调用方需持有锁
+//
+// 处理中的键不会被淘汰，它们的数量受服务器并发上限约束
+func (s *MemoryStore) evict() {
+	if s.maxEntries <= 0 {
+		return
+	}
+	for len(s.entries) >= s.maxEntries && s.lru.Len() > 0 {
+		key := s.lru.Back().Value.(string)
+		s.remove(key, s.entries[key])
+	}
+}
+
+// remove 删除一个键，调用方需持有锁
+func (s *MemoryStore) remove(key string, e *entry) {
+	if e.element != nil {
+		s.lru.Remove(e.element)
+	}
+	delete(s.entries, key)
+}
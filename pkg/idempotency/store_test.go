@@ -0,0 +1,183 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+func TestMemoryStoreConcurrentBeginExecutesOnce(t *testing.T) {
+	store := NewMemoryStore(time.Hour, clock.NewFake(time.Unix(0, 0)))
+	fingerprint := Fingerprint(http.MethodPost, "/orders", []byte(`{"id":1}`))
+
+	const requests = 50
+	var executed atomic.Int32
+	var wg sync.WaitGroup
+	responses := make([]*Response, requests)
+	errs := make([]error, requests)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response, err := store.Begin(context.Background(), "key", fingerprint)
+			if err == nil && response == nil {
+				// 只有第一个请求执行并保存响应
+				executed.Add(1)
+				response = &Response{StatusCode: http.StatusCreated, Body: []byte("created")}
+				store.Complete("key", response)
+			}
+			responses[i], errs[i] = response, err
+		}(i)
+	}
+	wg.Wait()
+
+	if got := executed.Load(); got != 1 {
+		t.Fatalf("executed %d times, want 1", got)
+	}
+	for i := range responses {
+		if errs[i] != nil {
+			t.Fatalf("request %d: %v", i, errs[i])
+		}
+		if responses[i].StatusCode != http.StatusCreated {
+			t.Fatalf("request %d got status %d, want the saved 201", i, responses[i].StatusCode)
+		}
+	}
+}
+
+func TestMemoryStoreAbortLetsWaiterExecute(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil)
+
+	if response, err := store.Begin(context.Background(), "key", "fp"); response != nil || err != nil {
+		t.Fatalf("first Begin got %v, %v", response, err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		response, err := store.Begin(context.Background(), "key", "fp")
+		if err == nil && response != nil {
+			err = errors.New("waiter got a response from an aborted request")
+		}
+		result <- err
+	}()
+
+	store.Abort("key")
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryStoreWaiterHonorsContext(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil)
+	_, _ = store.Begin(context.Background(), "key", "fp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Begin(ctx, "key", "fp"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestMemoryStoreFingerprintMismatch(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil)
+	_, _ = store.Begin(context.Background(), "key", "fp-1")
+	store.Complete("key", &Response{StatusCode: http.StatusOK})
+
+	if _, err := store.Begin(context.Background(), "key", "fp-2"); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Fatalf("got %v, want ErrFingerprintMismatch", err)
+	}
+}
+
+func TestMemoryStoreExpiresAfterTTL(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	store := NewMemoryStore(time.Hour, fake)
+
+	_, _ = store.Begin(context.Background(), "key", "fp")
+	store.Complete("key", &Response{StatusCode: http.StatusOK})
+
+	fake.Advance(59 * time.Minute)
+	if response, _ := store.Begin(context.Background(), "key", "fp"); response == nil {
+		t.Fatal("key expired before TTL")
+	}
+
+	fake.Advance(time.Minute)
+	if response, err := store.Begin(context.Background(), "key", "fp"); response != nil || err != nil {
+		t.Fatalf("got %v, %v after TTL, want a fresh key", response, err)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil, WithMaxEntries(3))
+	complete := func(key string) {
+		_, _ = store.Begin(context.Background(), key, "fp")
+		store.Complete(key, &Response{StatusCode: http.StatusOK})
+	}
+
+	complete("a")
+	complete("b")
+	complete("c")
+
+	// 访问 a 后 b 成为最久未使用的键
+	if response, _ := store.Begin(context.Background(), "a", "fp"); response == nil {
+		t.Fatal("a missing before eviction")
+	}
+	complete("d")
+
+	if store.Len() != 3 {
+		t.Fatalf("%d entries, want 3", store.Len())
+	}
+	if response, _ := store.Begin(context.Background(), "b", "fp"); response != nil {
+		t.Fatal("b should have been evicted")
+	}
+}
+
+func TestMemoryStoreDoesNotEvictInFlight(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil, WithMaxEntries(2))
+
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("in-flight-%d", i)
+		if response, err := store.Begin(context.Background(), key, "fp"); response != nil || err != nil {
+			t.Fatalf("%s: got %v, %v", key, response, err)
+		}
+	}
+	if store.Len() != 3 {
+		t.Fatalf("%d entries, want all in-flight keys kept", store.Len())
+	}
+
+	// 处理中的键完成后才参与淘汰
+	store.Complete("in-flight-0", &Response{StatusCode: http.StatusOK})
+	_, _ = store.Begin(context.Background(), "new", "fp")
+	if store.Len() != 3 {
+		t.Fatalf("%d entries, want the completed key evicted for the new one", store.Len())
+	}
+}
+
+func TestMemoryStoreUniqueKeysBounded(t *testing.T) {
+	store := NewMemoryStore(time.Hour, nil, WithMaxEntries(100))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				if response, err := store.Begin(context.Background(), key, "fp"); response == nil && err == nil {
+					store.Complete(key, &Response{StatusCode: http.StatusOK})
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n := store.Len(); n > 100 {
+		t.Fatalf("%d entries, want at most 100", n)
+	}
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// Config 保存日志配置，对应配置文件中的 logging.*
+type Config struct {
+	Level  string // debug, info, warn, error, fatal
+	Format string // json, text
+	Output string // stdout, stderr, file
+	File   string // Output 为 file 时的日志文件路径
+
+	// 以下字段只在 Output 为 file 时生效
+	MaxSize        int64         // 单个文件的最大字节数，为0时不按大小轮转
+	RotateInterval time.Duration // 按时间轮转的间隔，为0时不按时间轮转
+	MaxBackups     int           // 保留的历史文件数，为0时不限制
+	MaxAge         time.Duration // 历史文件的最长保留时间，为0时不限制
+}
+
+// New 根据配置创建日志器，并添加从上下文中读取请求ID和追踪ID的钩子
+func New(config Config) (*logrus.Logger, error) {
+	logger := logrus.New()
+
+	level := config.Level
+	if level == "" {
+		level = "info"
+	}
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", config.Level, err)
+	}
+	logger.SetLevel(parsed)
+
+	switch strings.ToLower(config.Format) {
+	case "", "json":
+		logger.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true, TimestampFormat: time.RFC3339Nano})
+	default:
+		return nil, fmt.Errorf("unknown log format %q", config.Format)
+	}
+
+	switch strings.ToLower(config.Output) {
+	case "", "stdout":
+		logger.SetOutput(os.Stdout)
+	case "stderr":
+		logger.SetOutput(os.Stderr)
+	case "file":
+		file, err := NewRotatingFile(RotatingFileConfig{
+			Path:       config.File,
+			MaxSize:    config.MaxSize,
+			Interval:   config.RotateInterval,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+		})
+		if err != nil {
+			return nil, err
+		}
+		logger.SetOutput(file)
+	default:
+		return nil, fmt.Errorf("unknown log output %q", config.Output)
+	}
+
+	logger.AddHook(ContextHook{})
+
+	return logger, nil
+}
+
+// Close 关闭日志器的文件输出，输出为标准输出时不做任何事
+func Close(logger *logrus.Logger) error {
+	if closer, ok := logger.Out.(io.Closer); ok && logger.Out != os.Stdout && logger.Out != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+// defaultLogger 是未注入日志器的组件使用的全局日志器
+var defaultLogger atomic.Pointer[logrus.Logger]
+
+// SetDefault 设置全局日志器
+func SetDefault(logger *logrus.Logger) {
+	defaultLogger.Store(logger)
+}
+
+// Default 返回全局日志器，未设置时返回 logrus 的标准日志器
+func Default() *logrus.Logger {
+	if logger := defaultLogger.Load(); logger != nil {
+		return logger
+	}
+	return logrus.StandardLogger()
+}
+
+// ContextHook 从日志条目的上下文中读取请求ID和追踪ID并添加为字段
+type ContextHook struct{}
+
+// Levels 实现 logrus.Hook 接口
+func (ContextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 实现 logrus.Hook 接口
+func (ContextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	if id := requestid.FromContext(entry.Context); id != "" {
+		entry.Data["request_id"] = id
+	}
+	if sc := tracing.SpanContextFromContext(entry.Context); sc.IsValid() {
+		entry.Data["trace_id"] = sc.TraceID.String()
+		entry.Data["span_id"] = sc.SpanID.String()
+	}
+	return nil
+}
+
+// contextKey 是请求级日志条目在 context.Context 中的键类型
+type contextKey struct{}
+
+// NewContext 返回携带请求级日志条目的上下文
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext 返回上下文中的请求级日志条目，不存在时使用 base 创建，
+// base 为nil时使用全局日志器。返回的条目绑定 ctx，日志中会带上请求ID和追踪ID
+func FromContext(ctx context.Context, base *logrus.Logger) *logrus.Entry {
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry.WithContext(ctx)
+	}
+	if base == nil {
+		base = Default()
+	}
+	return base.WithContext(ctx)
+}
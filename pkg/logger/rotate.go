@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// backupTimeFormat 是历史日志文件名中的时间格式，按字典序即按时间排序
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFileConfig 保存日志文件轮转配置
+type RotatingFileConfig struct {
+	Path       string        // 当前日志文件路径
+	MaxSize    int64         // 单个文件的最大字节数，为0时不按大小轮转
+	Interval   time.Duration // 按时间轮转的间隔（按时间边界对齐），为0时不按时间轮转
+	MaxBackups int           // 保留的历史文件数，为0时不限制
+	MaxAge     time.Duration // 历史文件的最长保留时间，为0时不限制
+	Clock      clock.Clock   // 为nil时使用系统时钟
+}
+
+// RotatingFile 是按大小和时间轮转的日志文件，实现 io.WriteCloser
+//
+// 轮转时当前文件被重命名为 <name>-<时间><ext>，然后按 MaxBackups 和 MaxAge
+// 清理历史文件
+type RotatingFile struct {
+	config   RotatingFileConfig
+	clock    clock.Clock
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	mutex    sync.Mutex
+}
+
+// NewRotatingFile 打开日志文件，目录不存在时自动创建
+func NewRotatingFile(config RotatingFileConfig) (*RotatingFile, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("log file path is empty")
+	}
+
+	r := &RotatingFile{
+		config: config,
+		clock:  config.Clock,
+	}
+	if r.clock == nil {
+		r.clock = clock.Real()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write 实现 io.Writer 接口，写入前按需轮转
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// 轮转失败时仍写入当前文件，并返回轮转错误让调用方得知
+	var rotateErr error
+	if r.shouldRotate(int64(len(p))) {
+		rotateErr = r.rotate()
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate 立即轮转日志文件
+func (r *RotatingFile) Rotate() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rotate()
+}
+
+// Close 关闭日志文件
+func (r *RotatingFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open 以追加方式打开当前日志文件
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = r.clock.Now()
+	return nil
+}
+
+// shouldRotate 判断写入 n 字节前是否需要轮转
+func (r *RotatingFile) shouldRotate(n int64) bool {
+	// 空文件不按大小轮转，避免单条超大日志导致不断轮转
+	if r.config.MaxSize > 0 && r.size > 0 && r.size+n > r.config.MaxSize {
+		return true
+	}
+	if r.config.Interval > 0 {
+		now := r.clock.Now()
+		return !now.Truncate(r.config.Interval).Equal(r.openedAt.Truncate(r.config.Interval))
+	}
+	return false
+}
+
+// rotate 重命名当前文件、打开新文件并清理历史文件
+//
+// 旧文件在新文件打开之前保持打开：重命名失败时继续写入当前文件，
+// 打开新文件失败时继续写入已重命名的旧文件，轮转失败不会使日志中断
+func (r *RotatingFile) rotate() error {
+	now := r.clock.Now()
+	if err := os.Rename(r.config.Path, r.backupName(now)); err != nil && !os.IsNotExist(err) {
+		// 下一个轮转周期再重试，避免按时间轮转时每次写入都重试
+		r.openedAt = now
+		if r.file == nil {
+			if openErr := r.open(); openErr != nil {
+				return openErr
+			}
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	old := r.file
+	if err := r.open(); err != nil {
+		return fmt.Errorf("failed to open log file after rotation: %w", err)
+	}
+	if old != nil {
+		_ = old.Close()
+	}
+
+	r.cleanup()
+	return nil
+}
+
+// backupName 返回历史文件名
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.config.Path)
+	prefix := strings.TrimSuffix(r.config.Path, ext)
+	return prefix + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// cleanup 删除超出数量或超过保留时间的历史文件，失败时保留文件
+func (r *RotatingFile) cleanup() {
+	if r.config.MaxBackups <= 0 && r.config.MaxAge <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(r.config.Path)
+	prefix := strings.TrimSuffix(r.config.Path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+	}
+	var backups []backup
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(path, prefix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: path, time: t})
+	}
+
+	// 最新的在前
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].time.After(backups[j].time)
+	})
+
+	now := r.clock.Now()
+	for i, b := range backups {
+		expired := r.config.MaxAge > 0 && now.Sub(b.time) > r.config.MaxAge
+		overflow := r.config.MaxBackups > 0 && i >= r.config.MaxBackups
+		if expired || overflow {
+			_ = os.Remove(b.path)
+		}
+	}
+}
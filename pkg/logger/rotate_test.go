@@ -0,0 +1,242 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/requestid"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestRotatingFile 在临时目录中创建使用假时钟的轮转文件
+func newTestRotatingFile(t *testing.T, config RotatingFileConfig) (*RotatingFile, *clock.Fake) {
+	t.Helper()
+
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local))
+	config.Path = filepath.Join(t.TempDir(), "app.log")
+	config.Clock = fake
+	r, err := NewRotatingFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r, fake
+}
+
+// backups 返回按文件名排序的历史文件
+func backups(t *testing.T, r *RotatingFile) []string {
+	t.Helper()
+
+	matches, err := filepath.Glob(strings.TrimSuffix(r.config.Path, ".log") + "-*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// readFile 读取文件内容
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// mustWrite 写入一行并检查错误
+func mustWrite(t *testing.T, r *RotatingFile, line string) {
+	t.Helper()
+
+	if _, err := r.Write([]byte(line)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	r, fake := newTestRotatingFile(t, RotatingFileConfig{MaxSize: 10})
+
+	mustWrite(t, r, "12345\n")
+	mustWrite(t, r, "abc\n")
+	if got := backups(t, r); len(got) != 0 {
+		t.Fatalf("rotated before MaxSize: %v", got)
+	}
+
+	fake.Advance(time.Second)
+	mustWrite(t, r, "overflow\n")
+
+	got := backups(t, r)
+	if len(got) != 1 {
+		t.Fatalf("%d backups, want 1", len(got))
+	}
+	if content := readFile(t, got[0]); content != "12345\nabc\n" {
+		t.Errorf("backup contains %q", content)
+	}
+	if content := readFile(t, r.config.Path); content != "overflow\n" {
+		t.Errorf("current file contains %q", content)
+	}
+}
+
+func TestRotatingFileRotatesByInterval(t *testing.T) {
+	r, fake := newTestRotatingFile(t, RotatingFileConfig{Interval: time.Hour})
+
+	mustWrite(t, r, "first\n")
+	fake.Advance(59 * time.Minute)
+	mustWrite(t, r, "same hour\n")
+	if got := backups(t, r); len(got) != 0 {
+		t.Fatalf("rotated within the interval: %v", got)
+	}
+
+	// 跨过整点边界时轮转
+	fake.Advance(time.Minute)
+	mustWrite(t, r, "next hour\n")
+
+	got := backups(t, r)
+	if len(got) != 1 {
+		t.Fatalf("%d backups, want 1", len(got))
+	}
+	if content := readFile(t, got[0]); content != "first\nsame hour\n" {
+		t.Errorf("backup contains %q", content)
+	}
+	if content := readFile(t, r.config.Path); content != "next hour\n" {
+		t.Errorf("current file contains %q", content)
+	}
+}
+
+func TestRotatingFileCleanup(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackups int
+		maxAge     time.Duration
+		want       int
+	}{
+		{"unlimited", 0, 0, 5},
+		{"max backups", 2, 0, 2},
+		{"max age", 0, 150 * time.Minute, 3},
+		{"both", 1, 150 * time.Minute, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, fake := newTestRotatingFile(t, RotatingFileConfig{MaxBackups: tt.maxBackups, MaxAge: tt.maxAge})
+
+			// 每小时轮转一次，共产生5个历史文件
+			for i := 0; i < 5; i++ {
+				mustWrite(t, r, "line\n")
+				fake.Advance(time.Hour)
+				if err := r.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := backups(t, r); len(got) != tt.want {
+				t.Fatalf("%d backups kept, want %d: %v", len(got), tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRenameFails(t *testing.T) {
+	r, fake := newTestRotatingFile(t, RotatingFileConfig{MaxSize: 10})
+	mustWrite(t, r, "before\n")
+
+	// 以历史文件名创建非空目录，使重命名失败
+	fake.Advance(time.Second)
+	blocker := r.backupName(fake.Now())
+	if err := os.MkdirAll(filepath.Join(blocker, "keep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := r.Write([]byte("during\n"))
+	if err == nil {
+		t.Fatal("rotation failure was not reported")
+	}
+	if n != len("during\n") {
+		t.Fatalf("wrote %d bytes, want the line written despite the failure", n)
+	}
+
+	// 重命名恢复后继续轮转
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, r, "after\n")
+
+	if content := readFile(t, blocker); content != "before\nduring\n" {
+		t.Errorf("backup contains %q", content)
+	}
+	if content := readFile(t, r.config.Path); content != "after\n" {
+		t.Errorf("current file contains %q", content)
+	}
+}
+
+func TestRotatingFileRotateAfterClose(t *testing.T) {
+	r, _ := newTestRotatingFile(t, RotatingFileConfig{})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("closed\n")); err != os.ErrClosed {
+		t.Fatalf("got %v, want os.ErrClosed", err)
+	}
+}
+
+func TestContextHook(t *testing.T) {
+	var output bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&output)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(ContextHook{})
+
+	sc := tracing.SpanContext{
+		TraceID: tracing.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  tracing.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+	withIDs := tracing.ContextWithSpanContext(requestid.NewContext(context.Background(), "req-1"), sc)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]string
+	}{
+		{"no context", nil, map[string]string{}},
+		{"empty context", context.Background(), map[string]string{}},
+		{"request id only", requestid.NewContext(context.Background(), "req-1"), map[string]string{"request_id": "req-1"}},
+		{"request and trace ids", withIDs, map[string]string{
+			"request_id": "req-1",
+			"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span_id":    "00f067aa0ba902b7",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			entry := logrus.NewEntry(log)
+			if tt.ctx != nil {
+				entry = entry.WithContext(tt.ctx)
+			}
+			entry.Info("hello")
+
+			var fields map[string]interface{}
+			if err := json.Unmarshal(output.Bytes(), &fields); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"request_id", "trace_id", "span_id"} {
+				got, _ := fields[key].(string)
+				if got != tt.want[key] {
+					t.Errorf("%s = %q, want %q", key, got, tt.want[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header 是传递请求ID的HTTP头
+const Header = "X-Request-ID"
+
+// maxLength 是接受的外部请求ID的最大长度
+const maxLength = 128
+
+// contextKey 是请求ID在 context.Context 中的键类型
+type contextKey struct{}
+
+// NewContext 返回携带请求ID的上下文
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 返回上下文中的请求ID，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Generate 生成一个新的随机请求ID
+func Generate() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Valid 判断外部传入的请求ID是否可以直接使用
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		// 只接受可打印的ASCII字符，避免日志注入
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Transport 是在出站请求中附加请求ID的 http.RoundTripper
+type Transport struct {
+	Base http.RoundTripper // 为nil时使用 http.DefaultTransport
+}
+
+// NewTransport 创建附加请求ID的 Transport
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := FromContext(req.Context())
+	if id == "" || req.Header.Get(Header) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTripper 不应修改原始请求
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, id)
+	return base.RoundTrip(req)
+}
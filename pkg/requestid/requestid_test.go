@@ -0,0 +1,90 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"hex", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"printable punctuation", "client:req_1/2~", true},
+		{"max length", strings.Repeat("a", maxLength), true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", maxLength+1), false},
+		{"space", "req 1", false},
+		{"newline", "req-1\nlevel=error", false},
+		{"tab", "req\t1", false},
+		{"control", "req\x00", false},
+		{"delete", "req\x7f", false},
+		{"non ascii", "请求-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid(tt.id); got != tt.want {
+			t.Errorf("%s: Valid(%q) = %v, want %v", tt.name, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	a, b := Generate(), Generate()
+	if !Valid(a) || len(a) != 32 {
+		t.Fatalf("generated %q, want a valid 32 character ID", a)
+	}
+	if a == b {
+		t.Fatal("generated the same ID twice")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(Header))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	send := func(ctx context.Context, header string) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return req
+	}
+
+	ctx := NewContext(context.Background(), "req-1")
+
+	// 转发上下文中的请求ID，且不修改原始请求
+	req := send(ctx, "")
+	if req.Header.Get(Header) != "" {
+		t.Error("Transport modified the original request")
+	}
+	// 调用方显式设置的请求头优先
+	send(ctx, "explicit")
+	// 上下文中没有请求ID时不添加
+	send(context.Background(), "")
+
+	want := []string{"req-1", "explicit", ""}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Fatalf("server received %q, want %q", received, want)
+		}
+	}
+}
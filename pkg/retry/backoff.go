@@ -0,0 +1,155 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// 内置的退避策略名称，对应配置文件中的 retry.strategy
+const (
+	StrategyConstant           = "constant"
+	StrategyLinear             = "linear"
+	StrategyExponential        = "exponential"
+	StrategyFullJitter         = "full_jitter"
+	StrategyDecorrelatedJitter = "decorrelated_jitter"
+)
+
+// Backoff 定义退避策略
+type Backoff interface {
+	// Next 返回第 attempt 次尝试失败后的等待时间，prev 为上一次的等待时间（首次为0）
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// BackoffFunc 允许使用普通函数作为退避策略
+type BackoffFunc func(attempt int, prev time.Duration) time.Duration
+
+// Next 实现 Backoff 接口
+func (f BackoffFunc) Next(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// ConstantBackoff 每次重试等待固定时间
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+// Next 实现 Backoff 接口
+func (b ConstantBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	return b.Interval
+}
+
+// LinearBackoff 等待时间随尝试次数线性增长: Initial * attempt
+type LinearBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Next 实现 Backoff 接口
+func (b LinearBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	return capInterval(float64(b.Initial)*float64(attempt), b.Max)
+}
+
+// ExponentialBackoff 指数退避，在中心值上下按 RandomizationFactor 抖动
+type ExponentialBackoff struct {
+	Initial             time.Duration
+	Max                 time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+	Rand                clock.Rand // 随机数来源，为nil时使用全局随机源
+}
+
+// Next 实现 Backoff 接口
+func (b ExponentialBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	center := exponential(b.Initial, b.Multiplier, attempt)
+
+	// 先将抖动区间限制在最大间隔内，再取随机值，避免抖动越过上限
+	delta := b.RandomizationFactor * center
+	high := float64(capInterval(center+delta, b.Max))
+	low := math.Min(center-delta, high)
+
+	return time.Duration(low + randFloat64(b.Rand)*(high-low))
+}
+
+// FullJitterBackoff 指数退避加完全抖动: random(0, min(Max, Initial * Multiplier^(attempt-1)))
+type FullJitterBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Rand       clock.Rand // 随机数来源，为nil时使用全局随机源
+}
+
+// Next 实现 Backoff 接口
+func (b FullJitterBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	ceiling := float64(capInterval(exponential(b.Initial, b.Multiplier, attempt), b.Max))
+	return time.Duration(randFloat64(b.Rand) * ceiling)
+}
+
+// DecorrelatedJitterBackoff AWS风格的去相关抖动: min(Max, random(Base, prev * 3))
+type DecorrelatedJitterBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+	Rand clock.Rand // 随机数来源，为nil时使用全局随机源
+}
+
+// Next 实现 Backoff 接口
+func (b DecorrelatedJitterBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	if prev < b.Base {
+		prev = b.Base
+	}
+	low := float64(b.Base)
+	high := float64(prev) * 3
+	return capInterval(low+randFloat64(b.Rand)*(high-low), b.Max)
+}
+
+// NewBackoff 根据策略名称和重试配置创建退避策略，策略名为空时使用指数退避
+func NewBackoff(strategy string, config *Config) (Backoff, error) {
+	switch strategy {
+	case StrategyConstant:
+		return ConstantBackoff{Interval: config.InitialInterval}, nil
+	case StrategyLinear:
+		return LinearBackoff{Initial: config.InitialInterval, Max: config.MaxInterval}, nil
+	case "", StrategyExponential:
+		return ExponentialBackoff{
+			Initial:             config.InitialInterval,
+			Max:                 config.MaxInterval,
+			Multiplier:          config.Multiplier,
+			RandomizationFactor: config.RandomizationFactor,
+			Rand:                config.Rand,
+		}, nil
+	case StrategyFullJitter:
+		return FullJitterBackoff{
+			Initial:    config.InitialInterval,
+			Max:        config.MaxInterval,
+			Multiplier: config.Multiplier,
+			Rand:       config.Rand,
+		}, nil
+	case StrategyDecorrelatedJitter:
+		return DecorrelatedJitterBackoff{Base: config.InitialInterval, Max: config.MaxInterval, Rand: config.Rand}, nil
+	default:
+		return nil, fmt.Errorf("unknown retry strategy: %s", strategy)
+	}
+}
+
+// exponential 计算 initial * multiplier^(attempt-1)
+func exponential(initial time.Duration, multiplier float64, attempt int) float64 {
+	return float64(initial) * math.Pow(multiplier, float64(attempt-1))
+}
+
+// capInterval 将间隔限制在 max 以内，max<=0 表示不限制
+func capInterval(interval float64, max time.Duration) time.Duration {
+	if max > 0 && interval > float64(max) {
+		return max
+	}
+	return time.Duration(interval)
+}
+
+// randFloat64 从给定的随机源取值，为nil时使用全局随机源
+func randFloat64(r clock.Rand) float64 {
+	if r == nil {
+		return clock.GlobalRand().Float64()
+	}
+	return r.Float64()
+}
@@ -0,0 +1,92 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedRand 每次返回相同的随机数
+type fixedRand float64
+
+func (r fixedRand) Float64() float64 {
+	return float64(r)
+}
+
+func TestBackoffSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff Backoff
+		want    []time.Duration
+	}{
+		{
+			name:    "constant",
+			backoff: ConstantBackoff{Interval: 200 * time.Millisecond},
+			want:    []time.Duration{200 * time.Millisecond, 200 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:    "linear capped",
+			backoff: LinearBackoff{Initial: 100 * time.Millisecond, Max: 250 * time.Millisecond},
+			want:    []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			name: "exponential without jitter",
+			backoff: ExponentialBackoff{
+				Initial:    100 * time.Millisecond,
+				Max:        time.Second,
+				Multiplier: 2,
+			},
+			want: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+		{
+			name: "exponential lowest jitter",
+			backoff: ExponentialBackoff{
+				Initial:             100 * time.Millisecond,
+				Max:                 time.Second,
+				Multiplier:          2,
+				RandomizationFactor: 0.5,
+				Rand:                fixedRand(0),
+			},
+			want: []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+		},
+		{
+			name: "full jitter half",
+			backoff: FullJitterBackoff{
+				Initial:    100 * time.Millisecond,
+				Max:        300 * time.Millisecond,
+				Multiplier: 2,
+				Rand:       fixedRand(0.5),
+			},
+			want: []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 150 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				if got := tt.backoff.Next(i+1, 0); got != want {
+					t.Errorf("attempt %d: got %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecorrelatedJitterUsesPreviousInterval(t *testing.T) {
+	backoff := DecorrelatedJitterBackoff{Base: 100 * time.Millisecond, Max: time.Second, Rand: fixedRand(1)}
+
+	// random(Base, prev*3) 取上限时每次变为上一次的3倍，直到达到 Max
+	want := []time.Duration{300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	var prev time.Duration
+	for i, w := range want {
+		prev = backoff.Next(i+1, prev)
+		if prev != w {
+			t.Fatalf("attempt %d: got %v, want %v", i+1, prev, w)
+		}
+	}
+}
+
+func TestNewBackoffUnknownStrategy(t *testing.T) {
+	if _, err := NewBackoff("fibonacci", DefaultConfig()); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var (
+	// ErrRetryBudgetExhausted 表示重试预算已耗尽，放弃本次重试
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+)
+
+// BudgetConfig 保存重试预算配置
+type BudgetConfig struct {
+	Ratio               float64     // 允许的重试量占正常请求量的比例，如0.1表示10%
+	MinRetriesPerSecond float64     // 每秒保底可用的重试次数，保证低流量时也能重试
+	MaxTokens           float64     // 令牌上限，为0时取 MinRetriesPerSecond 与 10 中的较大值
+	Clock               clock.Clock // 用于按时间补充令牌的时钟，为nil时使用系统时钟
+}
+
+// DefaultBudgetConfig 返回默认的重试预算配置
+func DefaultBudgetConfig() BudgetConfig {
+	return BudgetConfig{
+		Ratio:               0.1,
+		MinRetriesPerSecond: 5,
+		MaxTokens:           100,
+	}
+}
+
+// BudgetStats 是重试预算的统计快照，计数均为累计值
+type BudgetStats struct {
+	Tokens      float64 // 当前可用令牌
+	Deposits    uint64  // 正常请求次数
+	Withdrawals uint64  // 被允许的重试次数
+	Rejections  uint64  // 因预算耗尽被拒绝的重试次数
+}
+
+// Budget 是多个调用方共享的重试预算（令牌桶）
+//
+// 每次正常调用存入 Ratio 个令牌，每次重试取出一个令牌；
+// 另外按 MinRetriesPerSecond 的速度随时间补充令牌
+type Budget struct {
+	config     BudgetConfig
+	tokens     float64
+	lastRefill time.Time
+	stats      BudgetStats
+	mutex      sync.Mutex
+}
+
+// NewBudget 创建新的重试预算
+func NewBudget(config BudgetConfig) *Budget {
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = config.MinRetriesPerSecond
+		if config.MaxTokens < 10 {
+			config.MaxTokens = 10
+		}
+	}
+
+	return &Budget{
+		config:     config,
+		tokens:     config.MinRetriesPerSecond,
+		lastRefill: config.Clock.Now(),
+	}
+}
+
+// Deposit 记录一次正常调用并存入令牌
+func (b *Budget) Deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	b.stats.Deposits++
+	b.addTokens(b.config.Ratio)
+}
+
+// Withdraw 尝试为一次重试取出令牌，预算不足时返回false
+func (b *Budget) Withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		b.stats.Rejections++
+		return false
+	}
+
+	b.tokens--
+	b.stats.Withdrawals++
+	return true
+}
+
+// Stats 返回当前的统计快照
+func (b *Budget) Stats() BudgetStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refill()
+	stats := b.stats
+	stats.Tokens = b.tokens
+	return stats
+}
+
+// refill 按时间补充保底令牌，调用方需持有锁
+func (b *Budget) refill() {
+	now := b.config.Clock.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+	b.addTokens(elapsed * b.config.MinRetriesPerSecond)
+}
+
+// addTokens 增加令牌并限制在上限内，调用方需持有锁
+func (b *Budget) addTokens(n float64) {
+	b.tokens += n
+	if b.tokens > b.config.MaxTokens {
+		b.tokens = b.config.MaxTokens
+	}
+}
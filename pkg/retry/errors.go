@@ -0,0 +1,37 @@
+package retry
+
+import "errors"
+
+// PermanentError 包装一个不可重试的错误，重试循环遇到它会立即停止
+type PermanentError struct {
+	Err error
+}
+
+// Error 返回原始错误信息
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误，便于 errors.Is/As 判断
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 将错误标记为永久性错误，nil 原样返回
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent 判断错误链中是否包含永久性错误
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// IsRetryable 是默认的重试判断：除永久性错误外的所有非nil错误都可重试
+func IsRetryable(err error) bool {
+	return err != nil && !IsPermanent(err)
+}
@@ -0,0 +1,106 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+// HedgeConfig 保存对冲请求配置，只应用于幂等操作
+type HedgeConfig struct {
+	Delay     time.Duration // 首个请求超过该时间未返回时发起对冲请求，通常取p95延迟
+	MaxHedges int           // 最多额外发起的对冲请求数
+	Budget    *Budget       // 对冲请求消耗的预算，为nil时不限制
+	Clock     clock.Clock   // 时钟，为nil时使用系统时钟
+}
+
+// hedgeResult 是一次对冲尝试的结果
+type hedgeResult[T any] struct {
+	value T
+	err   error
+}
+
+// Hedge 执行对冲请求：首个请求在 Delay 内未返回时并行发起新的请求，
+// 使用第一个成功的结果并取消其余请求。对冲请求只在 Delay 到期时发起，
+// 失败不会触发新的请求（那是重试的职责），所有已发起的请求都失败时返回最后一个错误
+func Hedge[T any](ctx context.Context, fn ValueFunc[T], config *HedgeConfig) (T, error) {
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real()
+	}
+
+	if config.Budget != nil {
+		config.Budget.Deposit()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 通道容量足够所有尝试写入，被取消的尝试不会阻塞
+	results := make(chan hedgeResult[T], config.MaxHedges+1)
+	launch := func() {
+		go func() {
+			value, err := fn(ctx)
+			results <- hedgeResult[T]{value: value, err: err}
+		}()
+	}
+
+	// canHedge 判断是否还能发起对冲请求，可以时消耗一次额度
+	hedges := 0
+	canHedge := func() bool {
+		if hedges >= config.MaxHedges {
+			return false
+		}
+		if config.Budget != nil && !config.Budget.Withdraw() {
+			return false
+		}
+		hedges++
+		return true
+	}
+
+	launch()
+	inFlight := 1
+
+	timer := clk.NewTimer(config.Delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case result := <-results:
+			inFlight--
+			if result.err == nil {
+				return result.value, nil
+			}
+			lastErr = result.err
+
+			// 永久性错误不会因为再次请求而成功
+			if IsPermanent(result.err) {
+				var zero T
+				return zero, result.err
+			}
+			if inFlight == 0 {
+				var zero T
+				return zero, lastErr
+			}
+		case <-timer.C():
+			if canHedge() {
+				launch()
+				inFlight++
+				timer.Reset(config.Delay)
+			}
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// HedgeDo 与 Hedge 相同，用于没有返回值的操作
+func HedgeDo(ctx context.Context, fn RetryFuncContext, config *HedgeConfig) error {
+	_, err := Hedge(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, config)
+	return err
+}
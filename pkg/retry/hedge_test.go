@@ -0,0 +1,117 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+func TestHedgeLaunchesOnDelay(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var calls atomic.Int32
+	slow := make(chan struct{})
+
+	done := make(chan string, 1)
+	go func() {
+		value, _ := Hedge(context.Background(), func(ctx context.Context) (string, error) {
+			if calls.Add(1) == 1 {
+				// 首个请求一直阻塞到被取消
+				select {
+				case <-slow:
+				case <-ctx.Done():
+				}
+				return "", ctx.Err()
+			}
+			return "hedged", nil
+		}, &HedgeConfig{Delay: 100 * time.Millisecond, MaxHedges: 1, Clock: fake})
+		done <- value
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(100 * time.Millisecond)
+
+	if value := <-done; value != "hedged" {
+		t.Fatalf("got %q, want the hedged result", value)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("%d calls, want 2", got)
+	}
+}
+
+func TestHedgeDoesNotRelaunchOnFailure(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var calls atomic.Int32
+
+	err := HedgeDo(context.Background(), func(context.Context) error {
+		calls.Add(1)
+		return errTransient
+	}, &HedgeConfig{Delay: 100 * time.Millisecond, MaxHedges: 2, Clock: fake})
+
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("got %v, want the attempt error", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("%d calls, want 1: a fast failure must not trigger a hedge", got)
+	}
+}
+
+func TestHedgeReturnsErrorWhenAllLaunchedFail(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- HedgeDo(context.Background(), func(context.Context) error {
+			calls.Add(1)
+			<-release
+			return errTransient
+		}, &HedgeConfig{Delay: 100 * time.Millisecond, MaxHedges: 3, Clock: fake})
+	}()
+
+	// 只推进一个延迟，发起一个对冲请求后两个请求都失败
+	fake.BlockUntil(1)
+	fake.Advance(100 * time.Millisecond)
+	for calls.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-done; !errors.Is(err, errTransient) {
+		t.Fatalf("got %v, want the attempt error", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("%d calls, want 2", got)
+	}
+}
+
+func TestHedgeBudgetLimitsHedges(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	budget := NewBudget(BudgetConfig{Clock: fake})
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- HedgeDo(context.Background(), func(context.Context) error {
+			calls.Add(1)
+			<-release
+			return nil
+		}, &HedgeConfig{Delay: 100 * time.Millisecond, MaxHedges: 1, Budget: budget, Clock: fake})
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(100 * time.Millisecond)
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("%d calls, want 1 with an empty budget", got)
+	}
+}
@@ -0,0 +1,82 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterHint 由携带服务端重试建议（如 Retry-After 头）的错误实现
+type RetryAfterHint interface {
+	// RetryAfter 返回服务端建议的等待时间
+	RetryAfter() time.Duration
+}
+
+// RetryAfterError 包装一个带有重试等待建议的错误
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+// Error 返回原始错误信息
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter 实现 RetryAfterHint 接口
+func (e *RetryAfterError) RetryAfter() time.Duration {
+	return e.Delay
+}
+
+// WithRetryAfter 为错误附加重试等待建议，nil 原样返回
+func WithRetryAfter(err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryAfterError{Err: err, Delay: delay}
+}
+
+// retryAfterFromError 从错误链中提取重试等待建议
+func retryAfterFromError(err error) (time.Duration, bool) {
+	var hint RetryAfterHint
+	if !errors.As(err, &hint) {
+		return 0, false
+	}
+	delay := hint.RetryAfter()
+	if delay <= 0 {
+		return 0, false
+	}
+	return delay, true
+}
+
+// ParseRetryAfter 解析 Retry-After 头，支持秒数和HTTP日期两种格式
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"delta seconds", "120", 2 * time.Minute, true},
+		{"zero seconds", "0", 0, true},
+		{"surrounding whitespace", " 5 ", 5 * time.Second, true},
+		{"IMF-fixdate", "Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"RFC 850 date", "Friday, 01-Mar-24 12:01:00 GMT", time.Minute, true},
+		{"ANSI C date", "Fri Mar  1 12:00:10 2024", 10 * time.Second, true},
+		{"past date", "Fri, 01 Mar 2024 11:59:00 GMT", 0, true},
+		{"negative seconds", "-5", 0, false},
+		{"fractional seconds", "1.5", 0, false},
+		{"empty", "", 0, false},
+		{"garbage", "soon", 0, false},
+		{"date without zone", "2024-03-01 12:00:30", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("ParseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRetryAfterFromError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+		ok   bool
+	}{
+		{"no hint", errTransient, 0, false},
+		{"hint", WithRetryAfter(errTransient, time.Second), time.Second, true},
+		{"wrapped hint", fmt.Errorf("payment: %w", WithRetryAfter(errTransient, time.Second)), time.Second, true},
+		{"non-positive hint", WithRetryAfter(errTransient, 0), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfterFromError(tt.err)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("got %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	if WithRetryAfter(nil, time.Second) != nil {
+		t.Error("WithRetryAfter(nil) returned a non-nil error")
+	}
+	if !errors.Is(WithRetryAfter(errTransient, time.Second), errTransient) {
+		t.Error("WithRetryAfter does not unwrap to the original error")
+	}
+}
@@ -3,14 +3,20 @@ package retry
 import (
 	"context"
 	"errors"
-	"math"
-	"math/rand"
+	"fmt"
 	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/saixiaoxi/high-availability-system/pkg/tracing"
 )
 
 var (
 	// ErrMaxRetriesReached 表示达到最大重试次数
 	ErrMaxRetriesReached = errors.New("max retries reached")
+	// ErrAttemptTimeout 表示单次尝试超过了 PerAttemptTimeout
+	ErrAttemptTimeout = errors.New("attempt timed out")
+	// ErrInsufficientDeadline 表示剩余的截止时间不足以完成下一次尝试
+	ErrInsufficientDeadline = errors.New("insufficient time left for next attempt")
 )
 
 // Config 保存重试配置
@@ -20,6 +26,26 @@ type Config struct {
 	MaxInterval         time.Duration // 最大重试间隔
 	Multiplier          float64       // 退避乘数
 	RandomizationFactor float64       // 随机因子 (0-1)
+	PerAttemptTimeout   time.Duration // 单次尝试的超时时间，为0时不限制
+	MinAttemptTime      time.Duration // 一次尝试至少需要的时间，剩余截止时间不足时不再重试
+
+	// RetryIf 判断错误是否应该重试，为nil时使用 IsRetryable
+	RetryIf func(error) bool
+	// Backoff 退避策略，为nil时根据上面的参数使用带抖动的指数退避
+	Backoff Backoff
+	// Budget 共享的重试预算，为nil时不限制重试量
+	Budget *Budget
+	// Clock 用于退避等待和截止时间判断的时钟，为nil时使用系统时钟
+	Clock clock.Clock
+	// Rand 退避抖动的随机数来源，为nil时使用全局随机源
+	Rand clock.Rand
+
+	// OnRetry 在一次尝试失败、即将等待 nextDelay 后重试时调用
+	OnRetry func(ctx context.Context, attempt int, err error, nextDelay time.Duration)
+	// OnGiveUp 在放弃重试、返回错误前调用，attempt 为已执行的尝试次数
+	OnGiveUp func(ctx context.Context, attempt int, err error)
+	// OnSuccess 在第 attempt 次尝试成功后调用
+	OnSuccess func(ctx context.Context, attempt int)
 }
 
 // DefaultConfig 返回默认重试配置
@@ -33,6 +59,44 @@ func DefaultConfig() *Config {
 	}
 }
 
+// shouldRetry 根据配置判断错误是否可以重试
+func (c *Config) shouldRetry(err error) bool {
+	// 永久性错误始终不重试
+	if IsPermanent(err) {
+		return false
+	}
+	// 单次尝试超时始终可以重试
+	if errors.Is(err, ErrAttemptTimeout) {
+		return true
+	}
+	if c.RetryIf != nil {
+		return c.RetryIf(err)
+	}
+	return IsRetryable(err)
+}
+
+// backoff 返回配置的退避策略
+func (c *Config) backoff() Backoff {
+	if c.Backoff != nil {
+		return c.Backoff
+	}
+	return ExponentialBackoff{
+		Initial:             c.InitialInterval,
+		Max:                 c.MaxInterval,
+		Multiplier:          c.Multiplier,
+		RandomizationFactor: c.RandomizationFactor,
+		Rand:                c.Rand,
+	}
+}
+
+// clock 返回配置的时钟
+func (c *Config) clock() clock.Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return clock.Real()
+}
+
 // RetryFunc 是重试的函数类型
 type RetryFunc func() error
 
@@ -41,90 +105,176 @@ type RetryFuncContext func(context.Context) error
 
 // Do 执行带重试的操作
 func Do(fn RetryFunc, config *Config) error {
-	var err error
-	var nextInterval time.Duration = config.InitialInterval
-
-	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		err = fn()
-		if err == nil {
-			return nil
-		}
+	return DoWithContext(context.Background(), func(context.Context) error {
+		return fn()
+	}, config)
+}
 
-		if attempt == config.MaxAttempts {
-			break
-		}
+// DoWithContext 执行带上下文和重试的操作
+//
+// 遇到不可重试的错误时立即返回该错误；重试次数耗尽时返回的错误
+// 同时包装原始错误和 ErrMaxRetriesReached，两者都可以通过 errors.Is 判断；
+// 重试预算耗尽时返回的错误包装原始错误和 ErrRetryBudgetExhausted；
+// 上下文取消时返回的错误包装上下文的错误和最后一次尝试的错误
+func DoWithContext(ctx context.Context, fn RetryFuncContext, config *Config) error {
+	_, err := DoWithReport(ctx, fn, config)
+	return err
+}
 
-		// 计算下一次重试的间隔
-		nextInterval = calculateNextInterval(nextInterval, attempt, config)
-		time.Sleep(nextInterval)
-	}
+// DoWithReport 与 DoWithContext 相同，但额外返回每次尝试的报告
+func DoWithReport(ctx context.Context, fn RetryFuncContext, config *Config) (Report, error) {
+	report, err := doWithReport(ctx, fn, config)
 
 	if err != nil {
-		return errors.New(err.Error() + ": " + ErrMaxRetriesReached.Error())
+		if config.OnGiveUp != nil {
+			config.OnGiveUp(ctx, report.Attempts, err)
+		}
+	} else if config.OnSuccess != nil {
+		config.OnSuccess(ctx, report.Attempts)
 	}
 
-	return err
+	return report, err
 }
 
-// DoWithContext 执行带上下文和重试的操作
-func DoWithContext(ctx context.Context, fn RetryFuncContext, config *Config) error {
+// doWithReport 实现重试循环
+func doWithReport(ctx context.Context, fn RetryFuncContext, config *Config) (Report, error) {
+	var report Report
 	var err error
-	var nextInterval time.Duration = config.InitialInterval
+	var nextInterval time.Duration
+	backoff := config.backoff()
+	clk := config.clock()
+
+	// 每次调用向预算存入令牌，重试时再取出
+	if config.Budget != nil {
+		config.Budget.Deposit()
+	}
 
 	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
 		// 检查上下文是否已取消
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return report, cancelled(ctx, err)
 		default:
 			// 继续重试
 		}
 
-		err = fn(ctx)
+		err = runAttempt(ctx, fn, config, attempt)
+		report.Attempts = attempt
+		report.Errors = append(report.Errors, err)
 		if err == nil {
-			return nil
+			return report, nil
+		}
+
+		// 不可重试的错误直接返回
+		if !config.shouldRetry(err) {
+			return report, err
 		}
 
 		if attempt == config.MaxAttempts {
 			break
 		}
 
-		// 计算下一次重试的间隔
-		nextInterval = calculateNextInterval(nextInterval, attempt, config)
+		// 预算耗尽时停止重试，避免放大下游压力
+		if config.Budget != nil && !config.Budget.Withdraw() {
+			return report, fmt.Errorf("%w: %w", err, ErrRetryBudgetExhausted)
+		}
+
+		// 计算下一次重试的间隔，服务端给出等待建议时优先使用
+		if delay, ok := retryAfterFromError(err); ok {
+			nextInterval = hintedInterval(ctx, delay, config, clk)
+		} else {
+			nextInterval = nextBackoff(backoff, attempt, nextInterval, config)
+		}
+
+		// 剩余时间不足以等待并完成下一次尝试时提前放弃
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(clk.Now()) < nextInterval+config.MinAttemptTime {
+			return report, fmt.Errorf("%w: %w", err, ErrInsufficientDeadline)
+		}
+
+		if config.OnRetry != nil {
+			config.OnRetry(ctx, attempt, err, nextInterval)
+		}
 
 		// 带上下文的等待
-		timer := time.NewTimer(nextInterval)
+		waitStart := clk.Now()
+		timer := clk.NewTimer(nextInterval)
 		select {
 		case <-ctx.Done():
 			timer.Stop()
-			return ctx.Err()
-		case <-timer.C:
+			report.TotalWait += clk.Since(waitStart)
+			return report, cancelled(ctx, err)
+		case <-timer.C():
 			// 继续下一次重试
 		}
+		report.TotalWait += clk.Since(waitStart)
 	}
 
 	if err != nil {
-		return errors.New(err.Error() + ": " + ErrMaxRetriesReached.Error())
+		return report, fmt.Errorf("%w: %w", err, ErrMaxRetriesReached)
 	}
 
-	return err
+	return report, err
 }
 
-// 计算指数退避的下一个间隔时间
-func calculateNextInterval(currentInterval time.Duration, attempt int, config *Config) time.Duration {
-	// 应用乘数来实现指数退避
-	interval := float64(currentInterval) * config.Multiplier
+// cancelled 返回上下文取消时的错误，之前的尝试失败过时同时包装最后一次的错误
+func cancelled(ctx context.Context, lastErr error) error {
+	if lastErr == nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: last error: %w", ctx.Err(), lastErr)
+}
 
-	// 应用随机因子 (指数退避加抖动)
-	delta := config.RandomizationFactor * interval
-	minInterval := interval - delta
-	maxInterval := interval + delta
+// hintedInterval 将服务端建议的等待时间限制在最大间隔和上下文截止时间之内
+func hintedInterval(ctx context.Context, delay time.Duration, config *Config, clk clock.Clock) time.Duration {
+	if config.MaxInterval > 0 && delay > config.MaxInterval {
+		delay = config.MaxInterval
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := deadline.Sub(clk.Now()); delay > remaining {
+			delay = remaining
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
 
-	// 获取随机间隔
-	interval = minInterval + (rand.Float64() * (maxInterval - minInterval + 1))
+// runAttempt 在独立的跨度中执行一次尝试，配置了 PerAttemptTimeout 时使用独立的子上下文
+func runAttempt(ctx context.Context, fn RetryFuncContext, config *Config, attempt int) error {
+	ctx, span := tracing.Start(ctx, "retry.attempt", tracing.WithAttributes(tracing.Attr("retry.attempt", attempt)))
+	defer span.End()
 
-	// 确保不超过最大间隔
-	interval = math.Min(interval, float64(config.MaxInterval))
+	err := runAttemptWithTimeout(ctx, fn, config)
+	span.RecordError(err)
+	return err
+}
+
+// runAttemptWithTimeout 执行一次尝试，单次尝试超时时返回包装 ErrAttemptTimeout 的错误
+func runAttemptWithTimeout(ctx context.Context, fn RetryFuncContext, config *Config) error {
+	if config.PerAttemptTimeout <= 0 {
+		return fn(ctx)
+	}
 
-	return time.Duration(interval)
+	attemptCtx, cancel := context.WithTimeout(ctx, config.PerAttemptTimeout)
+	defer cancel()
+
+	err := fn(attemptCtx)
+	// 只有子上下文超时而父上下文仍然有效时，才认为是单次尝试超时
+	if err != nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return fmt.Errorf("%w: %w", ErrAttemptTimeout, err)
+	}
+	return err
+}
+
+// nextBackoff 计算下一次重试的间隔，并确保不超过最大间隔
+func nextBackoff(backoff Backoff, attempt int, prev time.Duration, config *Config) time.Duration {
+	interval := backoff.Next(attempt, prev)
+	if interval < 0 {
+		interval = 0
+	}
+	if config.MaxInterval > 0 && interval > config.MaxInterval {
+		interval = config.MaxInterval
+	}
+	return interval
 }
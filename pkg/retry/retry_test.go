@@ -0,0 +1,212 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+)
+
+var errTransient = errors.New("transient")
+
+// failTimes 返回一个前 n 次调用失败、之后成功的函数
+func failTimes(n int) (RetryFuncContext, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= n {
+			return errTransient
+		}
+		return nil
+	}, &calls
+}
+
+func TestDoWithReportWaitsOnClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	fn, calls := failTimes(2)
+
+	var delays []time.Duration
+	config := &Config{
+		MaxAttempts: 3,
+		Backoff:     LinearBackoff{Initial: time.Second},
+		Clock:       fake,
+		OnRetry: func(_ context.Context, _ int, _ error, nextDelay time.Duration) {
+			delays = append(delays, nextDelay)
+		},
+	}
+
+	type outcome struct {
+		report Report
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		report, err := DoWithReport(context.Background(), fn, config)
+		done <- outcome{report, err}
+	}()
+
+	// 每次退避等待开始后再推进时钟
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second} {
+		fake.BlockUntil(1)
+		fake.Advance(wait)
+	}
+
+	o := <-done
+	if o.err != nil {
+		t.Fatalf("unexpected error: %v", o.err)
+	}
+	if *calls != 3 || o.report.Attempts != 3 {
+		t.Fatalf("got %d calls and %d attempts, want 3", *calls, o.report.Attempts)
+	}
+	if o.report.TotalWait != 3*time.Second {
+		t.Errorf("total wait %v, want 3s", o.report.TotalWait)
+	}
+	if len(delays) != 2 || delays[0] != time.Second || delays[1] != 2*time.Second {
+		t.Errorf("delays %v, want [1s 2s]", delays)
+	}
+}
+
+func TestDoWithReportMaxRetriesReached(t *testing.T) {
+	fn, _ := failTimes(10)
+	config := &Config{MaxAttempts: 2, Backoff: ConstantBackoff{}}
+
+	report, err := DoWithReport(context.Background(), fn, config)
+	if !errors.Is(err, ErrMaxRetriesReached) || !errors.Is(err, errTransient) {
+		t.Fatalf("got %v, want ErrMaxRetriesReached wrapping the last error", err)
+	}
+	if report.Attempts != 2 {
+		t.Errorf("attempts %d, want 2", report.Attempts)
+	}
+}
+
+func TestDoWithReportPermanentErrorStops(t *testing.T) {
+	calls := 0
+	err := DoWithContext(context.Background(), func(context.Context) error {
+		calls++
+		return Permanent(errTransient)
+	}, &Config{MaxAttempts: 5, Backoff: ConstantBackoff{}})
+
+	if calls != 1 || !IsPermanent(err) {
+		t.Fatalf("got %d calls and %v, want one call and a permanent error", calls, err)
+	}
+}
+
+func TestDoWithReportInsufficientDeadline(t *testing.T) {
+	// 假时钟不前进，剩余时间始终为截止时间与假时钟当前时间的差
+	now := time.Now()
+	fake := clock.NewFake(now)
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancel()
+
+	fn, calls := failTimes(10)
+	_, err := DoWithReport(ctx, fn, &Config{
+		MaxAttempts: 3,
+		Backoff:     ConstantBackoff{Interval: 2 * time.Hour},
+		Clock:       fake,
+	})
+
+	if !errors.Is(err, ErrInsufficientDeadline) {
+		t.Fatalf("got %v, want ErrInsufficientDeadline", err)
+	}
+	if *calls != 1 {
+		t.Errorf("calls %d, want 1", *calls)
+	}
+}
+
+func TestDoWithReportRetryAfterHintCapped(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	calls := 0
+	var delay time.Duration
+
+	done := make(chan error, 1)
+	go func() {
+		done <- DoWithContext(context.Background(), func(context.Context) error {
+			calls++
+			if calls == 1 {
+				return WithRetryAfter(errTransient, time.Minute)
+			}
+			return nil
+		}, &Config{
+			MaxAttempts: 2,
+			MaxInterval: 5 * time.Second,
+			Backoff:     ConstantBackoff{Interval: time.Millisecond},
+			Clock:       fake,
+			OnRetry: func(_ context.Context, _ int, _ error, nextDelay time.Duration) {
+				delay = nextDelay
+			},
+		})
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(5 * time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if delay != 5*time.Second {
+		t.Errorf("delay %v, want Retry-After capped to 5s", delay)
+	}
+}
+
+func TestDoWithReportBudgetExhausted(t *testing.T) {
+	budget := NewBudget(BudgetConfig{Clock: clock.NewFake(time.Unix(0, 0))})
+	fn, calls := failTimes(10)
+
+	err := DoWithContext(context.Background(), fn, &Config{
+		MaxAttempts: 3,
+		Backoff:     ConstantBackoff{},
+		Budget:      budget,
+	})
+
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("got %v, want ErrRetryBudgetExhausted", err)
+	}
+	if *calls != 1 {
+		t.Errorf("calls %d, want 1", *calls)
+	}
+}
+
+func TestDoWithReportCancelledWrapsLastError(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	fn, calls := failTimes(10)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- DoWithContext(ctx, fn, &Config{
+			MaxAttempts: 3,
+			Backoff:     ConstantBackoff{Interval: time.Second},
+			Clock:       fake,
+		})
+	}()
+
+	// 在第一次退避等待期间取消
+	fake.BlockUntil(1)
+	cancel()
+
+	err := <-done
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errTransient) {
+		t.Fatalf("got %v, want context.Canceled wrapping the last error", err)
+	}
+	if want := "context canceled: last error: transient"; err.Error() != want {
+		t.Errorf("error %q, want %q", err, want)
+	}
+	if *calls != 1 {
+		t.Errorf("calls %d, want 1", *calls)
+	}
+}
+
+func TestDoWithReportCancelledBeforeFirstAttempt(t *testing.T) {
+	fn, calls := failTimes(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := DoWithReport(ctx, fn, &Config{MaxAttempts: 3, Backoff: ConstantBackoff{}})
+	if err != context.Canceled {
+		t.Fatalf("got %v, want bare context.Canceled", err)
+	}
+	if *calls != 0 || report.Attempts != 0 {
+		t.Errorf("got %d calls and %d attempts, want none", *calls, report.Attempts)
+	}
+}
@@ -0,0 +1,49 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Report 记录一次带重试调用的执行情况
+type Report struct {
+	Attempts  int           // 实际执行的尝试次数
+	Errors    []error       // 每次尝试的错误，成功的尝试为nil
+	TotalWait time.Duration // 在退避等待上花费的总时间
+}
+
+// LastError 返回最后一次尝试的错误
+func (r Report) LastError() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return r.Errors[len(r.Errors)-1]
+}
+
+// ValueFunc 是带返回值的重试函数类型
+type ValueFunc[T any] func(context.Context) (T, error)
+
+// DoValue 执行带重试且有返回值的操作，返回第一次成功尝试的结果
+func DoValue[T any](ctx context.Context, fn ValueFunc[T], config *Config) (T, error) {
+	value, _, err := DoValueWithReport(ctx, fn, config)
+	return value, err
+}
+
+// DoValueWithReport 与 DoValue 相同，但额外返回每次尝试的报告
+func DoValueWithReport[T any](ctx context.Context, fn ValueFunc[T], config *Config) (T, Report, error) {
+	var result T
+	report, err := DoWithReport(ctx, func(ctx context.Context) error {
+		value, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		result = value
+		return nil
+	}, config)
+
+	if err != nil {
+		var zero T
+		return zero, report, err
+	}
+	return result, report, nil
+}
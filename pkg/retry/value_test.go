@@ -0,0 +1,48 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDoValueReturnsFirstSuccessfulValue(t *testing.T) {
+	calls := 0
+	value, err := DoValue(context.Background(), func(context.Context) (string, error) {
+		calls++
+		// 失败的尝试返回的值不会被使用
+		if calls < 3 {
+			return "partial", errTransient
+		}
+		return "order-42", nil
+	}, &Config{MaxAttempts: 5, Backoff: ConstantBackoff{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "order-42" || calls != 3 {
+		t.Fatalf("got %q after %d calls, want order-42 after 3", value, calls)
+	}
+}
+
+func TestDoValueReturnsZeroValueOnPermanentError(t *testing.T) {
+	errDeclined := errors.New("declined")
+	calls := 0
+	value, report, err := DoValueWithReport(context.Background(), func(context.Context) (int, error) {
+		calls++
+		if calls == 1 {
+			return 7, errTransient
+		}
+		return 42, Permanent(errDeclined)
+	}, &Config{MaxAttempts: 5, Backoff: ConstantBackoff{}})
+
+	if !errors.Is(err, errDeclined) {
+		t.Fatalf("got %v, want the permanent error", err)
+	}
+	if value != 0 {
+		t.Errorf("value %d, want the zero value", value)
+	}
+	if report.Attempts != 2 || !errors.Is(report.LastError(), errDeclined) {
+		t.Errorf("got %d attempts, last error %v, want 2 attempts ending with the permanent error", report.Attempts, report.LastError())
+	}
+}
@@ -0,0 +1,270 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Exporter 将已结束的跨度发送到追踪后端
+type Exporter interface {
+	// Export 导出一批跨度
+	Export(ctx context.Context, spans []SpanData) error
+	// Shutdown 释放导出器持有的资源
+	Shutdown(ctx context.Context) error
+}
+
+// OTLPExporter 通过 OTLP/HTTP (JSON编码) 将跨度发送到采集器
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPExporter 创建OTLP导出器，endpoint 为完整地址，如 http://localhost:4318/v1/traces
+func NewOTLPExporter(endpoint string, timeout time.Duration, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		// 导出请求本身不传播追踪，避免产生追踪导出的跨度
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Export 实现 Exporter 接口
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown 实现 Exporter 接口
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// WriterExporter 将跨度以每行一个JSON对象的形式写入 io.Writer，用于离线调试
+type WriterExporter struct {
+	writer io.Writer
+	closer io.Closer
+	mutex  sync.Mutex
+}
+
+// NewWriterExporter 创建写入 w 的导出器
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{writer: w}
+}
+
+// NewStdoutExporter 创建写入标准输出的导出器
+func NewStdoutExporter() *WriterExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+// NewFileExporter 创建追加写入文件的导出器，目录不存在时自动创建
+func NewFileExporter(path string) (*WriterExporter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterExporter{writer: file, closer: file}, nil
+}
+
+// Export 实现 Exporter 接口
+func (e *WriterExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	encoder := json.NewEncoder(e.writer)
+	for _, span := range spans {
+		if err := encoder.Encode(otlpSpanFrom(span)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown 实现 Exporter 接口
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// 以下类型对应 OTLP/JSON 的 ExportTraceServiceRequest 结构
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// otlpRequest 按服务名分组构造OTLP导出请求
+func otlpRequest(spans []SpanData) otlpTraceRequest {
+	var req otlpTraceRequest
+	index := make(map[string]int)
+
+	for _, span := range spans {
+		i, ok := index[span.ServiceName]
+		if !ok {
+			var rs otlpResourceSpans
+			rs.Resource.Attributes = otlpAttributes([]Attribute{Attr("service.name", span.ServiceName)})
+			rs.ScopeSpans = make([]otlpScopeSpans, 1)
+			rs.ScopeSpans[0].Scope.Name = "github.com/saixiaoxi/high-availability-system/pkg/tracing"
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+			i = len(req.ResourceSpans) - 1
+			index[span.ServiceName] = i
+		}
+
+		scope := &req.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, otlpSpanFrom(span))
+	}
+
+	return req
+}
+
+// otlpSpanFrom 将跨度数据转换为OTLP格式
+func otlpSpanFrom(span SpanData) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: unixNano(span.StartTime),
+		EndTimeUnixNano:   unixNano(span.EndTime),
+		Attributes:        otlpAttributes(span.Attributes),
+		Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+	}
+	if span.ParentSpanID.IsValid() {
+		s.ParentSpanID = span.ParentSpanID.String()
+	}
+	for _, event := range span.Events {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   otlpAttributes(event.Attributes),
+		})
+	}
+	return s
+}
+
+// otlpAttributes 将属性转换为OTLP的键值列表
+func otlpAttributes(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	result := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		case time.Duration:
+			s := strconv.FormatInt(v.Milliseconds(), 10)
+			value.IntValue = &s
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		result = append(result, otlpKeyValue{Key: attr.Key, Value: value})
+	}
+	return result
+}
+
+// unixNano 将时间格式化为OTLP/JSON使用的十进制纳秒字符串
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenSpans 返回覆盖所有属性类型和两个服务的固定跨度
+func goldenSpans() []SpanData {
+	start := time.Unix(1700000000, 123456789).UTC()
+	traceID := TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+
+	return []SpanData{
+		{
+			Name:        "GET /api/v1/orders/:id",
+			Kind:        SpanKindServer,
+			SpanContext: SpanContext{TraceID: traceID, SpanID: SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}, Sampled: true},
+			StartTime:   start,
+			EndTime:     start.Add(150 * time.Millisecond),
+			Attributes: []Attribute{
+				Attr("http.method", "GET"),
+				Attr("http.status_code", 500),
+				Attr("retry.attempts", int64(3)),
+				Attr("sample.ratio", 0.25),
+				Attr("cache.hit", false),
+				Attr("retry.delay", 1500*time.Millisecond),
+				Attr("peer", struct{ Host string }{"db"}),
+			},
+			Events: []Event{
+				{Name: "exception", Time: start.Add(100 * time.Millisecond), Attributes: []Attribute{Attr("exception.message", "boom")}},
+			},
+			Status:        StatusError,
+			StatusMessage: "internal error",
+			ServiceName:   "api",
+		},
+		{
+			Name:         "HTTP POST",
+			Kind:         SpanKindClient,
+			SpanContext:  SpanContext{TraceID: traceID, SpanID: SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, Sampled: true},
+			ParentSpanID: SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			StartTime:    start.Add(10 * time.Millisecond),
+			EndTime:      start.Add(60 * time.Millisecond),
+			ServiceName:  "payment",
+		},
+		{
+			Name:        "db.query",
+			Kind:        SpanKindInternal,
+			SpanContext: SpanContext{TraceID: traceID, SpanID: SpanID{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11}, Sampled: true},
+			StartTime:   start.Add(70 * time.Millisecond),
+			EndTime:     start.Add(80 * time.Millisecond),
+			Status:      StatusOK,
+			ServiceName: "api",
+		},
+	}
+}
+
+// assertGolden 比较JSON与 testdata 中的期望文件，-update 时重写期望文件
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, got, "", "  "); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(indented.Bytes(), want) {
+		t.Fatalf("%s mismatch (run go test -update to regenerate)\ngot:\n%s\nwant:\n%s", path, indented.Bytes(), want)
+	}
+}
+
+func TestOTLPRequestGolden(t *testing.T) {
+	body, err := json.Marshal(otlpRequest(goldenSpans()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "otlp_request.golden.json", body)
+}
+
+func TestOTLPExporterPostsPayload(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, time.Second, map[string]string{"Authorization": "Bearer token"})
+	if err := exporter.Export(context.Background(), goldenSpans()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", got)
+	}
+	if got := header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization %q, want the configured header", got)
+	}
+	assertGolden(t, "otlp_request.golden.json", body)
+}
+
+func TestOTLPExporterRejectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.URL, time.Second, nil)
+	if err := exporter.Export(context.Background(), goldenSpans()); err == nil {
+		t.Fatal("export succeeded on 503")
+	}
+}
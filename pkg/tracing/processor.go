@@ -0,0 +1,209 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/sirupsen/logrus"
+)
+
+// BatchConfig 保存批量处理器的配置
+type BatchConfig struct {
+	QueueSize     int           // 待导出跨度的队列容量，队列满时丢弃新跨度
+	BatchSize     int           // 每批导出的最大跨度数
+	FlushInterval time.Duration // 即使未满一批也导出的间隔
+	ExportTimeout time.Duration // 单次导出的超时时间
+
+	// Logger 记录导出失败，通常传入 pkg/logger 创建的日志器；
+	// pkg/logger 依赖本包，因此这里只依赖 logrus。为nil时使用 logrus 的标准日志器
+	Logger logrus.FieldLogger
+
+	Clock clock.Clock // 定时导出使用的时钟，为nil时使用系统时钟
+}
+
+// DefaultBatchConfig 返回默认批量处理配置
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		QueueSize:     2048,
+		BatchSize:     512,
+		FlushInterval: 5 * time.Second,
+		ExportTimeout: 10 * time.Second,
+	}
+}
+
+// BatchStats 是批量处理器的统计信息
+type BatchStats struct {
+	Exported  uint64 // 主导出器成功导出的跨度数
+	FellBack  uint64 // 写入备用导出器的跨度数
+	Dropped   uint64 // 被丢弃的跨度数
+	QueueSize int    // 当前队列中的跨度数
+}
+
+// BatchProcessor 在后台批量导出跨度
+//
+// 结束跨度只会尝试放入有界队列，不会阻塞业务请求。主导出器失败时，
+// 如果配置了备用导出器则写入备用导出器，否则丢弃该批跨度
+type BatchProcessor struct {
+	exporter Exporter
+	fallback Exporter
+	config   BatchConfig
+	queue    chan SpanData
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	exported atomic.Uint64
+	fellBack atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// NewBatchProcessor 创建批量处理器并启动后台导出，fallback 可以为nil
+func NewBatchProcessor(exporter, fallback Exporter, config BatchConfig) *BatchProcessor {
+	defaults := DefaultBatchConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.ExportTimeout <= 0 {
+		config.ExportTimeout = defaults.ExportTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = logrus.StandardLogger()
+	}
+	if config.Clock == nil {
+		config.Clock = clock.Real()
+	}
+
+	p := &BatchProcessor{
+		exporter: exporter,
+		fallback: fallback,
+		config:   config,
+		queue:    make(chan SpanData, config.QueueSize),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+// OnEnd 将已结束的跨度放入队列，队列已满或处理器已停止时丢弃
+func (p *BatchProcessor) OnEnd(span SpanData) {
+	select {
+	case <-p.stopChan:
+		p.dropped.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case p.queue <- span:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Stats 返回统计信息
+func (p *BatchProcessor) Stats() BatchStats {
+	return BatchStats{
+		Exported:  p.exported.Load(),
+		FellBack:  p.fellBack.Load(),
+		Dropped:   p.dropped.Load(),
+		QueueSize: len(p.queue),
+	}
+}
+
+// Shutdown 停止后台导出，导出队列中剩余的跨度并关闭导出器
+func (p *BatchProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		close(p.stopChan)
+	})
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	err := p.exporter.Shutdown(ctx)
+	if p.fallback != nil {
+		if fallbackErr := p.fallback.Shutdown(ctx); err == nil {
+			err = fallbackErr
+		}
+	}
+	return err
+}
+
+// run 收集跨度并按批大小或时间间隔导出
+func (p *BatchProcessor) run() {
+	defer close(p.done)
+
+	ticker := p.config.Clock.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, p.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		p.export(batch)
+		batch = make([]SpanData, 0, p.config.BatchSize)
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C():
+			flush()
+		case <-p.stopChan:
+			// 导出停止前已入队的跨度
+			for {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+					if len(batch) >= p.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export 导出一批跨度，主导出器失败时使用备用导出器或丢弃
+func (p *BatchProcessor) export(batch []SpanData) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.ExportTimeout)
+	defer cancel()
+
+	err := p.exporter.Export(ctx, batch)
+	if err == nil {
+		p.exported.Add(uint64(len(batch)))
+		return
+	}
+
+	if p.fallback != nil {
+		if fallbackErr := p.fallback.Export(ctx, batch); fallbackErr == nil {
+			p.fellBack.Add(uint64(len(batch)))
+			return
+		}
+	}
+
+	p.dropped.Add(uint64(len(batch)))
+	p.config.Logger.WithError(err).Warnf("Failed to export %d spans", len(batch))
+}
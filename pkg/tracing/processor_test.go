@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saixiaoxi/high-availability-system/pkg/clock"
+	"github.com/sirupsen/logrus"
+)
+
+// failingExporter 总是导出失败
+type failingExporter struct{}
+
+func (failingExporter) Export(ctx context.Context, spans []SpanData) error {
+	return errors.New("collector unavailable")
+}
+
+func (failingExporter) Shutdown(ctx context.Context) error { return nil }
+
+// lockedBuffer 是可以并发写入的缓冲区
+type lockedBuffer struct {
+	buf   bytes.Buffer
+	mutex sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestBatchProcessorLogsDroppedBatch(t *testing.T) {
+	var output lockedBuffer
+	log := logrus.New()
+	log.SetOutput(&output)
+
+	config := DefaultBatchConfig()
+	config.FlushInterval = time.Hour
+	config.Logger = log
+	p := NewBatchProcessor(failingExporter{}, nil, config)
+
+	p.OnEnd(SpanData{Name: "a"})
+	p.OnEnd(SpanData{Name: "b"})
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := p.Stats(); stats.Dropped != 2 || stats.Exported != 0 {
+		t.Fatalf("got %+v, want 2 dropped", stats)
+	}
+	if got := output.String(); !strings.Contains(got, "Failed to export 2 spans") || !strings.Contains(got, "collector unavailable") {
+		t.Fatalf("log output %q does not report the dropped batch", got)
+	}
+}
+
+func TestBatchProcessorFallsBack(t *testing.T) {
+	var output bytes.Buffer
+	config := DefaultBatchConfig()
+	config.FlushInterval = time.Hour
+	p := NewBatchProcessor(failingExporter{}, NewWriterExporter(&output), config)
+
+	p.OnEnd(SpanData{Name: "a"})
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := p.Stats(); stats.FellBack != 1 || stats.Dropped != 0 {
+		t.Fatalf("got %+v, want 1 span written to the fallback", stats)
+	}
+	if !strings.Contains(output.String(), `"name":"a"`) {
+		t.Fatalf("fallback output %q missing the span", output.String())
+	}
+}
+
+func TestBatchProcessorFlushesOnInterval(t *testing.T) {
+	var output lockedBuffer
+	fake := clock.NewFake(time.Unix(0, 0))
+	config := DefaultBatchConfig()
+	config.FlushInterval = time.Second
+	config.Clock = fake
+	p := NewBatchProcessor(NewWriterExporter(&output), nil, config)
+	defer p.Shutdown(context.Background())
+
+	p.OnEnd(SpanData{Name: "a"})
+	fake.BlockUntil(1)
+	eventually(t, func() bool { return p.Stats().QueueSize == 0 })
+
+	// 未满一批的跨度在间隔到达前不导出
+	fake.Advance(999 * time.Millisecond)
+	if stats := p.Stats(); stats.Exported != 0 {
+		t.Fatalf("got %+v before the flush interval", stats)
+	}
+
+	fake.Advance(time.Millisecond)
+	eventually(t, func() bool { return p.Stats().Exported == 1 })
+	if !strings.Contains(output.String(), `"name":"a"`) {
+		t.Fatalf("output %q missing the span", output.String())
+	}
+}
+
+// waitFor 等待条件成立，超过一秒后测试失败
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name    string
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-" + traceID + "-" + spanID + "-01", true, true},
+		{"not sampled", "00-" + traceID + "-" + spanID + "-00", true, false},
+		{"other flag bits", "00-" + traceID + "-" + spanID + "-03", true, true},
+		{"surrounding spaces", "  00-" + traceID + "-" + spanID + "-01 ", true, true},
+		{"future version with extra field", "01-" + traceID + "-" + spanID + "-01-extra", true, true},
+		{"empty", "", false, false},
+		{"too few fields", "00-" + traceID + "-" + spanID, false, false},
+		{"version 00 with extra field", "00-" + traceID + "-" + spanID + "-01-extra", false, false},
+		{"invalid version ff", "ff-" + traceID + "-" + spanID + "-01", false, false},
+		{"version too long", "000-" + traceID + "-" + spanID + "-01", false, false},
+		{"uppercase trace id", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01", false, false},
+		{"short trace id", "00-4bf92f3577b34da6-" + spanID + "-01", false, false},
+		{"short span id", "00-" + traceID + "-00f067aa-01", false, false},
+		{"non hex span id", "00-" + traceID + "-00f067aa0ba902zz-01", false, false},
+		{"non hex flags", "00-" + traceID + "-" + spanID + "-0x", false, false},
+		{"long flags", "00-" + traceID + "-" + spanID + "-001", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + spanID + "-01", false, false},
+		{"zero span id", "00-" + traceID + "-0000000000000000-01", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if !ok {
+				if sc != (SpanContext{}) {
+					t.Fatalf("got %+v on failure, want zero SpanContext", sc)
+				}
+				return
+			}
+			if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID {
+				t.Errorf("got trace %s span %s", sc.TraceID, sc.SpanID)
+			}
+			if sc.Sampled != tt.sampled {
+				t.Errorf("sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+			if !sc.Remote {
+				t.Error("parsed SpanContext not marked remote")
+			}
+		})
+	}
+}
+
+func TestFormatTraceparentRoundTrip(t *testing.T) {
+	sc := SpanContext{
+		TraceID: TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	}
+
+	for _, sampled := range []bool{true, false} {
+		sc.Sampled = sampled
+		value := FormatTraceparent(sc)
+
+		want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+		if sampled {
+			want = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		}
+		if value != want {
+			t.Fatalf("FormatTraceparent = %q, want %q", value, want)
+		}
+
+		parsed, ok := ParseTraceparent(value)
+		if !ok {
+			t.Fatalf("failed to parse formatted %q", value)
+		}
+		parsed.Remote = false
+		if parsed != sc {
+			t.Fatalf("round trip got %+v, want %+v", parsed, sc)
+		}
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	header := http.Header{}
+	Inject(context.Background(), header)
+	if header.Get(TraceparentHeader) != "" {
+		t.Fatal("injected traceparent without a span")
+	}
+
+	const value = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header.Set(TraceparentHeader, value)
+	ctx := Extract(context.Background(), header)
+
+	out := http.Header{}
+	Inject(ctx, out)
+	if got := out.Get(TraceparentHeader); got != value {
+		t.Fatalf("propagated %q, want %q", got, value)
+	}
+}
@@ -0,0 +1,338 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID 是W3C Trace Context中的16字节追踪ID
+type TraceID [16]byte
+
+// String 返回十六进制表示
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid 判断追踪ID是否非全零
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID 是W3C Trace Context中的8字节跨度ID
+type SpanID [8]byte
+
+// String 返回十六进制表示
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid 判断跨度ID是否非全零
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 是可以跨进程传播的跨度标识
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool // 是否从上游请求中提取
+}
+
+// IsValid 判断跨度标识是否有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind 表示跨度的类型，取值与OTLP一致
+type SpanKind int
+
+const (
+	// SpanKindInternal 进程内部操作
+	SpanKindInternal SpanKind = 1
+	// SpanKindServer 处理入站请求
+	SpanKindServer SpanKind = 2
+	// SpanKindClient 发起出站请求
+	SpanKindClient SpanKind = 3
+)
+
+// StatusCode 表示跨度的状态，取值与OTLP一致
+type StatusCode int
+
+const (
+	// StatusUnset 未设置状态
+	StatusUnset StatusCode = 0
+	// StatusOK 操作成功
+	StatusOK StatusCode = 1
+	// StatusError 操作失败
+	StatusError StatusCode = 2
+)
+
+// Attribute 是跨度上的键值属性
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr 创建一个属性
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Event 是跨度上带时间戳的事件
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData 是已结束跨度的只读数据，交给导出器导出
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+	ServiceName   string
+}
+
+// Span 表示一次操作，nil 的 *Span 可以安全调用所有方法
+type Span struct {
+	provider *Provider
+	data     SpanData
+	ended    bool
+	mutex    sync.Mutex
+}
+
+// SpanContext 返回跨度标识
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording 判断跨度是否会被导出
+func (s *Span) IsRecording() bool {
+	return s != nil && s.provider != nil && s.data.SpanContext.Sampled
+}
+
+// SetAttributes 设置属性
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// AddEvent 添加事件
+func (s *Span) AddEvent(name string, attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+// RecordError 记录错误并将状态设为错误，err 为nil时不做任何事
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.AddEvent("exception", Attr("exception.message", err.Error()))
+	s.SetStatus(StatusError, err.Error())
+}
+
+// SetStatus 设置状态
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Status = code
+	s.data.StatusMessage = message
+}
+
+// End 结束跨度并交给处理器导出，重复调用无效
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	s.provider.processor.OnEnd(data)
+}
+
+// StartOption 是创建跨度的选项
+type StartOption func(*SpanData)
+
+// WithSpanKind 设置跨度类型，默认为 SpanKindInternal
+func WithSpanKind(kind SpanKind) StartOption {
+	return func(d *SpanData) {
+		d.Kind = kind
+	}
+}
+
+// WithAttributes 设置初始属性
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(d *SpanData) {
+		d.Attributes = append(d.Attributes, attrs...)
+	}
+}
+
+// ProviderConfig 保存追踪提供者配置
+type ProviderConfig struct {
+	ServiceName string          // 服务名称，导出为 service.name 资源属性
+	SampleRatio float64         // 根跨度的采样比例 (0-1)
+	Processor   *BatchProcessor // 已结束跨度的处理器
+}
+
+// Provider 创建跨度并将其交给处理器
+type Provider struct {
+	config    ProviderConfig
+	processor *BatchProcessor
+}
+
+// NewProvider 创建新的追踪提供者
+func NewProvider(config ProviderConfig) *Provider {
+	return &Provider{
+		config:    config,
+		processor: config.Processor,
+	}
+}
+
+// Start 创建一个跨度，上下文中有父跨度时作为其子跨度
+func (p *Provider) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	data := SpanData{
+		Name:        name,
+		Kind:        SpanKindInternal,
+		StartTime:   time.Now(),
+		ServiceName: p.config.ServiceName,
+	}
+	for _, opt := range opts {
+		opt(&data)
+	}
+
+	// 有父跨度时沿用其追踪ID和采样决定，否则按比例采样
+	if parent.IsValid() {
+		data.SpanContext.TraceID = parent.TraceID
+		data.SpanContext.Sampled = parent.Sampled
+		data.ParentSpanID = parent.SpanID
+	} else {
+		data.SpanContext.TraceID = newTraceID()
+		data.SpanContext.Sampled = sample(data.SpanContext.TraceID, p.config.SampleRatio)
+	}
+	data.SpanContext.SpanID = newSpanID()
+
+	span := &Span{provider: p, data: data}
+	if p.processor == nil {
+		span.provider = nil
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Shutdown 导出剩余的跨度并停止处理器
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.processor == nil {
+		return nil
+	}
+	return p.processor.Shutdown(ctx)
+}
+
+// globalProvider 是全局追踪提供者
+var globalProvider atomic.Pointer[Provider]
+
+// SetProvider 设置全局追踪提供者
+func SetProvider(p *Provider) {
+	globalProvider.Store(p)
+}
+
+// Start 使用全局追踪提供者创建跨度，未设置提供者时返回原上下文和nil跨度
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	p := globalProvider.Load()
+	if p == nil {
+		return ctx, nil
+	}
+	return p.Start(ctx, name, opts...)
+}
+
+// contextKey 是跨度在 context.Context 中的键类型
+type contextKey struct{}
+
+// ContextWithSpan 返回携带跨度的上下文
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, contextKey{}, span)
+}
+
+// ContextWithSpanContext 返回携带跨度标识的上下文，用于远程父跨度或跨goroutine传递
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return ContextWithSpan(ctx, &Span{data: SpanData{SpanContext: sc}})
+}
+
+// SpanFromContext 返回上下文中的跨度，不存在时返回nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext 返回上下文中的跨度标识
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	return SpanFromContext(ctx).SpanContext()
+}
+
+// newTraceID 生成随机追踪ID
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// newSpanID 生成随机跨度ID
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// sample 根据追踪ID的低8字节按比例采样，同一追踪在不同服务中的决定一致
+func sample(id TraceID, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+
+	var x uint64
+	for _, b := range id[8:] {
+		x = x<<8 | uint64(b)
+	}
+	return float64(x>>1) < ratio*float64(uint64(1)<<63)
+}